@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Integration tokens are refreshed this long before they expire, so requests
+// in flight don't race the expiry
+const tokenExpiryMargin = time.Minute
+
+// tokenSource provides the bearer token of the Sentry requests
+type tokenSource interface {
+	// token returns the token to send
+	token() (string, error)
+	// refused is told a token was refused, or rate limited, and tells whether
+	// the request is worth retrying with a new token and how long to wait first
+	refused(token string, resp *http.Response) (retry bool, wait time.Duration)
+}
+
+// staticToken is a personal or internal integration auth token
+type staticToken string
+
+func (t staticToken) token() (string, error) {
+	return string(t), nil
+}
+
+func (t staticToken) refused(token string, resp *http.Response) (bool, time.Duration) {
+	return false, 0
+}
+
+// integrationToken authenticates as a Sentry integration installation with its
+// client credentials, exchanging the refresh token for a new token whenever the
+// current one expires or is refused
+type integrationToken struct {
+	ClientId	string
+	ClientSecret	string
+	InstallationId	string
+	RefreshToken	string
+	// RefreshTokenFile keeps the refresh token across runs, Sentry issues a
+	// new one on every refresh
+	RefreshTokenFile	string
+	options		*Options
+	current		string
+	expiresAt	time.Time
+	// fresh tells the current token replaced a refused one, if it's refused
+	// too the credentials are the problem
+	fresh		bool
+	forced		bool
+	mutex		sync.Mutex
+}
+
+type integrationAuthorization struct {
+	Token		string	`json:"token"`
+	RefreshToken	string	`json:"refreshToken"`
+	ExpiresAt	string	`json:"expiresAt"`
+}
+
+// loadTokens picks the credentials of the Sentry requests out of the
+// environment, nil when there are none
+func loadTokens(options *Options) (tokenSource, error) {
+	if tokens := os.Getenv("SENTRY_TOKENS"); tokens != "" {
+		return newRotatingTokens(tokens)
+	}
+
+	if token := os.Getenv("SENTRY_TOKEN"); token != "" {
+		return staticToken(token), nil
+	}
+
+	if os.Getenv("SENTRY_CLIENT_ID") == "" {
+		return nil, nil
+	}
+
+	source := &integrationToken{
+		ClientId: os.Getenv("SENTRY_CLIENT_ID"),
+		ClientSecret: os.Getenv("SENTRY_CLIENT_SECRET"),
+		InstallationId: os.Getenv("SENTRY_INSTALLATION_ID"),
+		RefreshToken: os.Getenv("SENTRY_REFRESH_TOKEN"),
+		RefreshTokenFile: os.Getenv("SENTRY_REFRESH_TOKEN_FILE"),
+		options: options,
+	}
+
+	if source.RefreshTokenFile != "" {
+		content, err := ioutil.ReadFile(source.RefreshTokenFile)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, &configError{fmt.Sprintf("Can't read SENTRY_REFRESH_TOKEN_FILE '%v': %v", source.RefreshTokenFile, err)}
+		}
+
+		if trimmed := strings.TrimSpace(string(content)); trimmed != "" {
+			source.RefreshToken = trimmed
+		}
+	}
+
+	if source.ClientSecret == "" || source.InstallationId == "" || source.RefreshToken == "" {
+		return nil, &configError{"Integration authentication needs SENTRY_CLIENT_ID, SENTRY_CLIENT_SECRET, SENTRY_INSTALLATION_ID and SENTRY_REFRESH_TOKEN or SENTRY_REFRESH_TOKEN_FILE."}
+	}
+
+	return source, nil
+}
+
+func (t *integrationToken) token() (string, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.current != "" && time.Now().Add(tokenExpiryMargin).Before(t.expiresAt) {
+		return t.current, nil
+	}
+
+	err := t.refresh()
+	if err != nil {
+		return "", err
+	}
+
+	return t.current, nil
+}
+
+func (t *integrationToken) refused(token string, resp *http.Response) (bool, time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		return false, 0
+	}
+
+	// Another request refreshed it already
+	if token != t.current {
+		return true, 0
+	}
+
+	if t.fresh {
+		return false, 0
+	}
+
+	t.current, t.forced = "", true
+
+	return true, 0
+}
+
+// refresh exchanges the refresh token for a new token, the mutex is held
+func (t *integrationToken) refresh() error {
+	uri := fmt.Sprintf("%s0/sentry-app-installations/%s/authorizations/", sentryURL, t.InstallationId)
+
+	body, err := json.Marshal(map[string]string{
+		"grant_type": "refresh_token",
+		"refresh_token": t.RefreshToken,
+		"client_id": t.ClientId,
+		"client_secret": t.ClientSecret,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", uri, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	for name, value := range t.options.SentryHeaders {
+		req.Header.Set(name, value)
+	}
+
+	req.Header.Set("User-Agent", t.options.UserAgent)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Error while refreshing the integration token: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return &authError{Method: "POST", URI: uri, StatusCode: resp.StatusCode}
+	}
+
+	var authorization integrationAuthorization
+
+	err = json.NewDecoder(resp.Body).Decode(&authorization)
+	if err != nil || authorization.Token == "" {
+		return fmt.Errorf("Invalid response from %s: %v", uri, err)
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339, authorization.ExpiresAt)
+	if err != nil {
+		// Without an expiry the token is used until Sentry refuses it
+		expiresAt = time.Now().Add(24 * time.Hour)
+	}
+
+	t.current, t.expiresAt = authorization.Token, expiresAt
+	t.fresh, t.forced = t.forced, false
+
+	if authorization.RefreshToken != "" {
+		t.RefreshToken = authorization.RefreshToken
+
+		if t.RefreshTokenFile != "" {
+			err = ioutil.WriteFile(t.RefreshTokenFile, []byte(t.RefreshToken+"\n"), 0600)
+			if err != nil {
+				return fmt.Errorf("Can't save the refresh token to '%v': %v", t.RefreshTokenFile, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Rate limited tokens are put aside this long when Sentry doesn't tell
+const rateLimitCooldown = time.Minute
+
+// rotatingTokens fails over along a list of tokens, a refused token is put
+// aside for the rest of the run and a rate limited one until its quota resets
+type rotatingTokens struct {
+	tokens		[]string
+	current		int
+	revoked		map[int]bool
+	limited		map[int]time.Time
+	mutex		sync.Mutex
+}
+
+func newRotatingTokens(value string) (tokenSource, error) {
+	rotating := &rotatingTokens{revoked: make(map[int]bool), limited: make(map[int]time.Time)}
+
+	for _, token := range strings.Split(value, ",") {
+		if token = strings.TrimSpace(token); token != "" {
+			rotating.tokens = append(rotating.tokens, token)
+		}
+	}
+
+	if len(rotating.tokens) == 0 {
+		return nil, &configError{"SENTRY_TOKENS has no tokens, use a comma separated list."}
+	}
+
+	return rotating, nil
+}
+
+func (t *rotatingTokens) token() (string, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.revoked[t.current] {
+		return "", &configError{"Sentry refused every token of SENTRY_TOKENS."}
+	}
+
+	return t.tokens[t.current], nil
+}
+
+func (t *rotatingTokens) refused(token string, resp *http.Response) (bool, time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// Another request rotated already
+	if token != t.tokens[t.current] {
+		return true, 0
+	}
+
+	// A 403 is about what was requested, the token still works elsewhere
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		t.revoked[t.current] = true
+	case http.StatusTooManyRequests:
+		cooldown := rateLimitCooldown
+
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			cooldown = time.Duration(seconds) * time.Second
+		}
+
+		t.limited[t.current] = time.Now().Add(cooldown)
+	default:
+		return false, 0
+	}
+
+	if t.rotate() {
+		return true, 0
+	}
+
+	// With every token rate limited the request waits for the first quota to
+	// reset, a refused token can't be used anymore
+	next := -1
+
+	for i := range t.tokens {
+		if !t.revoked[i] && (next < 0 || t.limited[i].Before(t.limited[next])) {
+			next = i
+		}
+	}
+
+	if next < 0 {
+		return false, 0
+	}
+
+	t.current = next
+
+	return true, t.limited[next].Sub(time.Now())
+}
+
+// rotate moves to the next token that's neither refused nor rate limited,
+// the mutex is held
+func (t *rotatingTokens) rotate() bool {
+	for i := 1; i < len(t.tokens); i++ {
+		next := (t.current + i) % len(t.tokens)
+
+		if !t.revoked[next] && time.Now().After(t.limited[next]) {
+			t.current = next
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const (
+	azureLoginURL		= "https://login.microsoftonline.com/"
+	azureMonitorResource	= "https://monitoring.azure.com/"
+)
+
+// azureMonitorSink emits custom metrics on an Azure resource, authenticating
+// as a service principal with the Monitoring Metrics Publisher role
+type azureMonitorSink struct {
+	TenantId	string
+	ClientId	string
+	ClientSecret	string
+	Region		string
+	ResourceId	string
+	Namespace	string
+}
+
+func newAzureMonitorSink() *azureMonitorSink {
+	sink := &azureMonitorSink{
+		TenantId: os.Getenv("AZURE_TENANT_ID"),
+		ClientId: os.Getenv("AZURE_CLIENT_ID"),
+		ClientSecret: os.Getenv("AZURE_CLIENT_SECRET"),
+		Region: os.Getenv("AZURE_MONITOR_REGION"),
+		ResourceId: os.Getenv("AZURE_MONITOR_RESOURCE_ID"),
+		Namespace: os.Getenv("AZURE_MONITOR_NAMESPACE"),
+	}
+
+	if sink.Namespace == "" {
+		sink.Namespace = "Sentry"
+	}
+
+	return sink
+}
+
+func (s *azureMonitorSink) Name() string {
+	return "Azure Monitor"
+}
+
+func (s *azureMonitorSink) Publish(report Report) error {
+	if s.Region == "" || s.TenantId == "" {
+		return fmt.Errorf("AZURE_MONITOR_REGION and AZURE_TENANT_ID are required")
+	}
+
+	token, err := s.token()
+	if err != nil {
+		return err
+	}
+
+	metrics := []struct {
+		Name		string
+		Total		*float64
+		Project		func(ProjectMetrics) *float64
+	}{
+		{"MTTR", report.MTTR, func(p ProjectMetrics) *float64 { return p.MTTR }},
+		{"MTBF", report.MTBF, func(p ProjectMetrics) *float64 { return p.MTBF }},
+	}
+
+	for _, metric := range metrics {
+		if metric.Total == nil {
+			continue
+		}
+
+		err = s.emit(token, metric.Name, nil, []azureSeries{newAzureSeries(nil, *metric.Total)})
+		if err != nil {
+			return err
+		}
+
+		var series []azureSeries
+		for _, project := range report.PerProject {
+			if value := metric.Project(project); value != nil {
+				series = append(series, newAzureSeries([]string{project.Organization + "/" + project.Project}, *value))
+			}
+		}
+
+		if len(series) > 0 {
+			err = s.emit(token, metric.Name, []string{"Project"}, series)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// azureSeries is a pre-aggregated value, a single sample per run
+type azureSeries struct {
+	DimValues	[]string	`json:"dimValues,omitempty"`
+	Min		float64		`json:"min"`
+	Max		float64		`json:"max"`
+	Sum		float64		`json:"sum"`
+	Count		int		`json:"count"`
+}
+
+func newAzureSeries(dimValues []string, value float64) azureSeries {
+	return azureSeries{DimValues: dimValues, Min: value, Max: value, Sum: value, Count: 1}
+}
+
+func (s *azureMonitorSink) emit(token string, metric string, dimNames []string, series []azureSeries) error {
+	uri := fmt.Sprintf("https://%s.monitoring.azure.com%s/metrics", s.Region, s.ResourceId)
+
+	body := map[string]interface{}{
+		"time": time.Now().UTC().Format(timeFormat),
+		"data": map[string]interface{}{
+			"baseData": map[string]interface{}{
+				"metric": metric,
+				"namespace": s.Namespace,
+				"dimNames": dimNames,
+				"series": series,
+			},
+		},
+	}
+
+	return postJSON(uri, map[string]string{"Authorization": "Bearer " + token}, body)
+}
+
+// token gets an access token for Azure Monitor with the client credentials
+func (s *azureMonitorSink) token() (string, error) {
+	uri := fmt.Sprintf("%s%s/oauth2/token", azureLoginURL, s.TenantId)
+
+	resp, err := http.PostForm(uri, url.Values{
+		"grant_type": {"client_credentials"},
+		"client_id": {s.ClientId},
+		"client_secret": {s.ClientSecret},
+		"resource": {azureMonitorResource},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("POST %s returned HTTP %d", uri, resp.StatusCode)
+	}
+
+	var token struct {
+		AccessToken	string	`json:"access_token"`
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		return "", err
+	}
+
+	return token.AccessToken, nil
+}
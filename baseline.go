@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// Baseline holds the change of every metric since a previous report, keyed
+// by its path in the report, e.g. "mttr" or "per_project[acme/api].mtbf"
+type Baseline struct {
+	GeneratedAt	string			`json:"generated_at"`
+	Deltas		map[string]float64	`json:"deltas"`
+}
+
+// Parts of the report that aren't compared, series and heatmaps are better
+// compared on their own
+var baselineSkipped = map[string]bool{
+	"metadata": true,
+	"baseline": true,
+	"heatmap": true,
+	"open_issues": true,
+	"diagnostics": true,
+}
+
+// Fields naming the items of the lists of a report, so items match across
+// reports whatever their order
+var baselineKeys = [][]string{
+	{"organization", "project"},
+	{"environment"},
+	{"owner"},
+	{"source"},
+}
+
+// loadBaseline reads a report written with --format json, encrypted or not
+func (c *Calculator) loadBaseline(path string) (baseline map[string]interface{}, err error) {
+	return c.loadReport("baseline", path)
+}
+
+// loadReport reads a JSON report, encrypted or not, what names it in errors
+func (c *Calculator) loadReport(what string, path string) (report map[string]interface{}, err error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, &configError{fmt.Sprintf("Can't read %s '%v': %v", what, path, err)}
+	}
+
+	if bytes.HasPrefix(content, []byte(encryptionMagic)) {
+		content, err = c.decrypt(content)
+		if err != nil {
+			return nil, &configError{fmt.Sprintf("Can't decrypt %s '%v': %v", what, path, err)}
+		}
+	}
+
+	err = json.Unmarshal(content, &report)
+	if err != nil {
+		return nil, &configError{fmt.Sprintf("The %s '%v' isn't a JSON report: %v", what, path, err)}
+	}
+
+	return
+}
+
+// compareBaseline returns the deltas of the metrics found in both reports
+func (c *Calculator) compareBaseline(report Report, baseline map[string]interface{}) (*Baseline, error) {
+	metrics, err := reportMetrics(report)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := make(map[string]float64)
+	flattenMetrics("", baseline, previous)
+
+	result := &Baseline{Deltas: make(map[string]float64)}
+
+	if metadata, ok := baseline["metadata"].(map[string]interface{}); ok {
+		result.GeneratedAt, _ = metadata["generated_at"].(string)
+	}
+
+	for path, value := range metrics {
+		if before, ok := previous[path]; ok {
+			result.Deltas[path] = value - before
+		}
+	}
+
+	return result, nil
+}
+
+// reportMetrics returns the numbers of a report by path
+func reportMetrics(report Report) (metrics map[string]float64, err error) {
+	content, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded map[string]interface{}
+
+	err = json.Unmarshal(content, &decoded)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics = make(map[string]float64)
+	flattenMetrics("", decoded, metrics)
+
+	return
+}
+
+// flattenMetrics collects the numbers of a decoded report by path
+func flattenMetrics(path string, value interface{}, metrics map[string]float64) {
+	switch value := value.(type) {
+	case float64:
+		metrics[path] = value
+	case map[string]interface{}:
+		for key, child := range value {
+			if baselineSkipped[key] {
+				continue
+			}
+
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+
+			flattenMetrics(childPath, child, metrics)
+		}
+	case []interface{}:
+		for i, child := range value {
+			flattenMetrics(fmt.Sprintf("%s[%s]", path, itemKey(child, i)), child, metrics)
+		}
+	}
+}
+
+// itemKey names an item of a list by its identifying fields, or its index
+func itemKey(item interface{}, index int) string {
+	fields, ok := item.(map[string]interface{})
+	if !ok {
+		return fmt.Sprintf("%d", index)
+	}
+
+	for _, keys := range baselineKeys {
+		var values []string
+
+		for _, key := range keys {
+			if value, ok := fields[key].(string); ok {
+				values = append(values, value)
+			}
+		}
+
+		if len(values) == len(keys) {
+			return strings.Join(values, "/")
+		}
+	}
+
+	return fmt.Sprintf("%d", index)
+}
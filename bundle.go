@@ -0,0 +1,324 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Entries of a bundle, the outputs keep their names under outputs/
+const (
+	bundleDataset	= "dataset.jsonl"
+	bundleConfig	= "config.json"
+	bundleVersion	= "version.json"
+	bundleReport	= "report.json"
+	bundleOutputs	= "outputs/"
+)
+
+// Recomputed metrics may differ by the order floats were summed in
+const verifyTolerance = 1e-9
+
+// addOutput records a file written by the run for the bundle
+func (c *Calculator) addOutput(file string) {
+	if file != stdoutArtifact && file != c.dumpPath() {
+		c.outputs = append(c.outputs, file)
+	}
+}
+
+// writeBundle archives what it takes to reproduce the report: the dataset,
+// the effective configuration, the version and the outputs along with the
+// report itself. The report and the dataset are encrypted when the outputs are
+func (c *Calculator) writeBundle(dataset string, report Report) (err error) {
+	file, err := os.Create(c.Options.Bundle)
+	if err != nil {
+		return fmt.Errorf("Can't create bundle '%v': %v", c.Options.Bundle, err)
+	}
+
+	defer func() {
+		closeErr := file.Close()
+		if err == nil && closeErr != nil {
+			err = fmt.Errorf("Can't write bundle '%v': %v", c.Options.Bundle, closeErr)
+		}
+	}()
+
+	compressed := gzip.NewWriter(file)
+	archive := tar.NewWriter(compressed)
+
+	// Headers don't change the numbers and may hold gateway credentials
+	options := *c.Options
+	options.SentryHeaders = nil
+
+	config, err := json.MarshalIndent(options, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	version, err := json.MarshalIndent(report.Metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	var buffer bytes.Buffer
+	c.writeReport(&buffer, report)
+
+	reportName, content := bundleReport, buffer.Bytes()
+
+	if c.Options.Encrypt {
+		reportName += encryptedSuffix
+
+		content, err = c.encrypt(content)
+		if err != nil {
+			return err
+		}
+	}
+
+	entries := []struct {
+		name	string
+		content	[]byte
+	}{{bundleConfig, config}, {bundleVersion, version}, {reportName, content}}
+
+	for _, entry := range entries {
+		err = addBundleEntry(archive, entry.name, int64(len(entry.content)), bytes.NewReader(entry.content))
+		if err != nil {
+			return fmt.Errorf("Can't write bundle '%v': %v", c.Options.Bundle, err)
+		}
+	}
+
+	outputs := append([]string{dataset}, c.outputs...)
+
+	if c.signingKey != nil {
+		outputs = append(outputs, manifestFile, manifestFile+signatureSuffix)
+	}
+
+	for i, output := range outputs {
+		name := bundleOutputs + filepath.Base(output)
+
+		// The dataset was sealed as it was written, like the report
+		if i == 0 && c.Options.Encrypt {
+			name = bundleDataset + encryptedSuffix
+		} else if i == 0 {
+			name = bundleDataset
+		}
+
+		err = addBundleFile(archive, name, output)
+		if err != nil {
+			return fmt.Errorf("Can't write bundle '%v': %v", c.Options.Bundle, err)
+		}
+	}
+
+	err = archive.Close()
+	if err == nil {
+		err = compressed.Close()
+	}
+
+	if err != nil {
+		return fmt.Errorf("Can't write bundle '%v': %v", c.Options.Bundle, err)
+	}
+
+	c.Log.Info(fmt.Sprintf("Output file '%v'", c.Options.Bundle))
+
+	return nil
+}
+
+func addBundleFile(archive *tar.Writer, name string, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	return addBundleEntry(archive, name, info.Size(), file)
+}
+
+func addBundleEntry(archive *tar.Writer, name string, size int64, content io.Reader) error {
+	err := archive.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: size, ModTime: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(archive, content)
+
+	return err
+}
+
+// extractBundle unpacks the regular files of a bundle into dir
+func extractBundle(bundle string, dir string) error {
+	file, err := os.Open(bundle)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	compressed, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+
+	archive := tar.NewReader(compressed)
+
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean(header.Name)
+		if header.Typeflag != tar.TypeReg || path.IsAbs(name) || strings.HasPrefix(name, "..") {
+			continue
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(name))
+
+		err = os.MkdirAll(filepath.Dir(target), 0700)
+		if err != nil {
+			return err
+		}
+
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(out, archive)
+		out.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Verify recomputes the report of a bundle out of its dataset with its
+// configuration and tells whether the numbers match the bundled report
+func (c *Calculator) Verify(bundle string) int {
+	dir, err := ioutil.TempDir("", "sentry-mttr-mtbf-verify-")
+	if err != nil {
+		c.Log.Error(err.Error())
+		return exitFailure
+	}
+
+	defer os.RemoveAll(dir)
+
+	err = extractBundle(bundle, dir)
+	if err != nil {
+		c.Log.Error(fmt.Sprintf("Can't read bundle '%v': %v", bundle, err))
+		return exitConfig
+	}
+
+	config, err := ioutil.ReadFile(filepath.Join(dir, bundleConfig))
+	if err != nil {
+		c.Log.Error(fmt.Sprintf("Bundle '%v' has no %s.", bundle, bundleConfig))
+		return exitConfig
+	}
+
+	options := new(Options)
+
+	err = json.Unmarshal(config, options)
+	if err != nil {
+		c.Log.Error(fmt.Sprintf("Invalid %s in bundle '%v': %v", bundleConfig, bundle, err))
+		return exitConfig
+	}
+
+	reportPath, datasetPath := filepath.Join(dir, bundleReport), filepath.Join(dir, bundleDataset)
+	if options.Encrypt {
+		reportPath += encryptedSuffix
+		datasetPath += encryptedSuffix
+	}
+
+	// The key of an encrypted bundle is the one given to verify
+	published, err := c.loadReport("bundled report", reportPath)
+	if err != nil {
+		c.Log.Error(err.Error())
+		return exitConfig
+	}
+
+	if metadata, ok := published["metadata"].(map[string]interface{}); ok && metadata["version"] != version {
+		c.Log.Warn(fmt.Sprintf("Bundle '%v' was made by version %v, this is %v, numbers may differ", bundle, metadata["version"], version))
+	}
+
+	// Only the numbers are recomputed, nothing is written or sent
+	options.Command, options.Format, options.Quiet = "verify", formatJSON, c.Options.Quiet
+	options.Dump, options.Bundle, options.History, options.Baseline, options.SignKey = "", "", "", "", ""
+	options.KeyFile = c.Options.KeyFile
+
+	verifier := NewCalculator(options)
+	verifier.Log = c.Log
+
+	stats := verifier.newStats()
+	defer stats.close()
+
+	err = verifier.replay([]string{datasetPath}, stats)
+	if err != nil {
+		c.Log.Error(err.Error())
+		return exitFailure
+	}
+
+	report, err := verifier.compute(stats)
+	if err != nil {
+		c.Log.Error(err.Error())
+		return exitFailure
+	}
+
+	recomputed, err := reportMetrics(report)
+	if err != nil {
+		c.Log.Error(err.Error())
+		return exitFailure
+	}
+
+	expected := make(map[string]float64)
+	flattenMetrics("", published, expected)
+
+	var mismatches []string
+
+	for metric, value := range expected {
+		actual, ok := recomputed[metric]
+
+		switch {
+		case !ok:
+			mismatches = append(mismatches, fmt.Sprintf("%s: published %v, not recomputed", metric, value))
+		case math.Abs(actual-value) > verifyTolerance*math.Max(1, math.Abs(value)):
+			mismatches = append(mismatches, fmt.Sprintf("%s: published %v, recomputed %v", metric, value, actual))
+		}
+	}
+
+	for metric, value := range recomputed {
+		if _, ok := expected[metric]; !ok {
+			mismatches = append(mismatches, fmt.Sprintf("%s: not published, recomputed %v", metric, value))
+		}
+	}
+
+	if len(mismatches) > 0 {
+		sort.Strings(mismatches)
+
+		for _, mismatch := range mismatches {
+			c.Log.Error(mismatch)
+		}
+
+		c.Log.Error(fmt.Sprintf("Bundle '%v' doesn't verify, %d metrics differ", bundle, len(mismatches)))
+		return exitFailure
+	}
+
+	c.Log.Info(fmt.Sprintf("Bundle '%v' verified, %d metrics match", bundle, len(expected)))
+
+	return exitSuccess
+}
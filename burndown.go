@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tealeg/xlsx"
+)
+
+const dayFormat = "2006-01-02"
+
+// DailyCount is the number of issues open at the end of a day in UTC
+type DailyCount struct {
+	Date		string	`json:"date"`
+	Open		int	`json:"open"`
+}
+
+// calcBurnDown accounts the days an issue was open, from first seen to its
+// last resolution, unresolved issues are still open today. Resolved issues
+// without a resolution in their activity, e.g. when MTTR is skipped, can't
+// be placed and are left out
+func (c *Calculator) calcBurnDown(issue Issue, stats *Stats) {
+	opened, err := time.Parse(timeFormat, issue.FirstSeen)
+	if err != nil {
+		c.Log.Debug(fmt.Sprintf("Issue #%v dropped from the burn-down, invalid first seen date", issue.Id))
+		return
+	}
+
+	var resolved time.Time
+
+	if issue.Status != "unresolved" {
+		for _, activity := range issue.Activity {
+			if !isResolution(activity.Type) {
+				continue
+			}
+
+			date, err := time.Parse(timeFormat, activity.DateCreated)
+			if err == nil && date.After(resolved) {
+				resolved = date
+			}
+		}
+
+		if resolved.IsZero() {
+			c.Log.Debug(fmt.Sprintf("Issue #%v dropped from the burn-down, no resolution", issue.Id))
+			return
+		}
+	}
+
+	stats.addOpenInterval(issue.Project, opened, resolved)
+}
+
+// burnDown turns the issues opened and resolved each day into the count of
+// open issues at the end of every day up to today
+func burnDown(deltas map[string]int, today time.Time) (series []DailyCount) {
+	var first string
+
+	for day := range deltas {
+		if first == "" || day < first {
+			first = day
+		}
+	}
+
+	if first == "" {
+		return
+	}
+
+	day, _ := time.Parse(dayFormat, first)
+	last := today.UTC().Format(dayFormat)
+	open := 0
+
+	for ; day.Format(dayFormat) <= last; day = day.AddDate(0, 0, 1) {
+		open += deltas[day.Format(dayFormat)]
+		series = append(series, DailyCount{Date: day.Format(dayFormat), Open: open})
+	}
+
+	return
+}
+
+func (c *Calculator) saveBurnDownIntoXLSX(projects []ProjectMetrics) {
+	var file *xlsx.File
+	var sheet *xlsx.Sheet
+	var row *xlsx.Row
+	var cell *xlsx.Cell
+	var err error
+
+	outputFile := fmt.Sprintf("burndown_%v", sheetName)
+
+	c.Log.Info(fmt.Sprintf("Output file '%v'", outputFile))
+
+	file = xlsx.NewFile()
+	sheet, err = file.AddSheet(c.text("sheet.open_issues"))
+	if err != nil {
+		panic(err.Error())
+	}
+
+	row = sheet.AddRow()
+	cell = row.AddCell()
+	cell.Value = c.text("heading.project_name")
+	cell = row.AddCell()
+	cell.Value = c.text("heading.date")
+	cell = row.AddCell()
+	cell.Value = c.text("heading.open_issues")
+
+	for _, project := range projects {
+		for _, count := range project.OpenIssues {
+			row = sheet.AddRow()
+			cell = row.AddCell()
+			cell.Value = project.Name
+			cell = row.AddCell()
+			cell.Value = c.formatDayCell(count.Date)
+			cell = row.AddCell()
+			setCountCell(cell, count.Open)
+		}
+	}
+
+	c.saveXLSX(file, outputFile)
+}
@@ -1,11 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"crypto/ed25519"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/bradfitz/slice"
@@ -20,6 +29,21 @@ import (
 
 type Calculator struct {
 	Log		*logrus.Logger
+	Options		*Options
+	failures	int
+	aborted		bool
+	dataset		*dataset
+	teams		map[string]Team
+	deploys		map[string][]Deploy
+	signingKey	ed25519.PrivateKey
+	artifacts	[]Artifact
+	outputs		[]string
+	history		*history
+	progress	progress
+	report		*Report
+	activities	[]ComputedActivity
+	stats		*Stats
+	mutex		sync.Mutex
 }
 
 type Organization struct {
@@ -29,6 +53,7 @@ type Organization struct {
 }
 
 type Project struct {
+	Id		string `json:"id"`
 	Name		string `json:"name"`
 	Slug		string `json:"slug"`
 	Organization	Organization
@@ -37,8 +62,17 @@ type Project struct {
 type Issue struct {
 	Id		string `json:"id"`
 	Status		string `json:"status"`
+	Substatus	string `json:"substatus"`
 	Project		Project
 	Activity		[]Activity
+	Owners		[]IssueOwner `json:"owners"`
+	AssignedTo	*Assignee `json:"assignedTo"`
+	Environment	string `json:"environment,omitempty"`
+	Owner		string `json:"owner,omitempty"`
+	FirstSeen	string `json:"firstSeen"`
+	FirstRelease	*Release `json:"firstRelease"`
+	TimeToDetect	*float64 `json:"timeToDetect,omitempty"`
+	ReleasedAt	string `json:"releasedAt,omitempty"`
 }
 
 type Activity struct {
@@ -50,6 +84,15 @@ type Activity struct {
 type Event struct {
 	Id		string `json:"eventID"`
 	DateCreated	string `json:"dateCreated"`
+	Tags		[]Tag `json:"tags"`
+	// Groups of the event, kept for the sorted pass collapsing bursts
+	Project		string `json:"-"`
+	Owner		string `json:"-"`
+}
+
+type Tag struct {
+	Key		string `json:"key"`
+	Value		string `json:"value"`
 }
 
 type ComputedEvent struct {
@@ -60,90 +103,630 @@ type ComputedEvent struct {
 type ComputedActivity struct {
 	Issue		Issue
 	Duration	float64
+	Assignments	float64
+	AssignedDuration	float64
+	Target		float64
+	Breached	bool
 }
 
 const (
 	sentryURL	= "https://sentry.io/api/"
+	projectsPerIssuesRequest	= 50
 	timeFormat	= "2006-01-02T15:04:05Z07:00"
 	sheetName	= "result.xlsx"
+	maxSheetRows	= 1048576
+	noEnvironment	= "(none)"
+	autoResolved	= "set_resolved_by_age"
+	tokenNeeded	= "Sentry token need, set SENTRY_TOKEN or the SENTRY_CLIENT_ID integration credentials."
 )
 
 var (
-	activities	[]ComputedActivity
-	events		[]Event
-	eventsMTBF	[]ComputedEvent
-	issues		[]Issue
-	projects	[]Project
-	sentryToken	string
+	sentryTokens	tokenSource
 )
 
 func main() {
-	sentryToken = os.Getenv("SENTRY_TOKEN")
+	os.Exit(run())
+}
 
-	if sentryToken == "" {
-		panic("Sentry token need.")
+func run() (code int) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", r)
+			code = exitFailure
+		}
+	}()
+
+	options, err := parseOptions()
+	if err == flag.ErrHelp {
+		flag.CommandLine.SetOutput(os.Stderr)
+		flag.Usage()
+		return exitSuccess
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfig
 	}
 
-	calculator := NewCalculator()
-	calculator.Start()
+	switch {
+	case options.Version || options.Command == "version":
+		fmt.Println(versionString())
+		return exitSuccess
+	case options.Command == "completion":
+		if len(options.Args) != 1 {
+			fmt.Fprintln(os.Stderr, "Usage: completion bash|zsh|fish")
+			return exitConfig
+		}
+
+		script, err := completionScript(options.Args[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitConfig
+		}
+
+		fmt.Print(script)
+		return exitSuccess
+	case options.Command == "merge":
+		if len(options.Args) == 0 {
+			fmt.Fprintln(os.Stderr, "Usage: merge dataset.jsonl...")
+			return exitConfig
+		}
+
+		return NewCalculator(options).Merge(options.Args)
+	case options.Command == "decrypt":
+		if len(options.Args) < 1 || len(options.Args) > 2 {
+			fmt.Fprintln(os.Stderr, "Usage: decrypt report.enc [output]")
+			return exitConfig
+		}
+
+		output := ""
+		if len(options.Args) == 2 {
+			output = options.Args[1]
+		}
+
+		return NewCalculator(options).Decrypt(options.Args[0], output)
+	case options.Command == "verify":
+		if len(options.Args) != 1 {
+			fmt.Fprintln(os.Stderr, "Usage: verify run.tar.gz")
+			return exitConfig
+		}
+
+		return NewCalculator(options).Verify(options.Args[0])
+	case options.Command == "tui":
+		sentryTokens, err = loadTokens(options)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitConfig
+		}
+
+		if sentryTokens == nil {
+			fmt.Fprintln(os.Stderr, tokenNeeded)
+			return exitConfig
+		}
+
+		return NewCalculator(options).Dashboard()
+	case options.Command == "serve":
+		sentryTokens, err = loadTokens(options)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitConfig
+		}
+
+		if sentryTokens == nil && options.Interval > 0 {
+			fmt.Fprintln(os.Stderr, "Scheduled runs need the Sentry token, set SENTRY_TOKEN.")
+			return exitConfig
+		}
+
+		return NewCalculator(options).Serve()
+	case options.Command != "":
+		fmt.Fprintf(os.Stderr, "Unknown command '%s'.\n", options.Command)
+		return exitConfig
+	}
+
+	calculator := NewCalculator(options)
+	calculator.Log.Debug(versionString())
+
+	sentryTokens, err = loadTokens(options)
+	if err != nil {
+		calculator.Log.Error(err.Error())
+		return exitConfig
+	}
+
+	if sentryTokens == nil {
+		calculator.Log.Error(tokenNeeded)
+		return exitConfig
+	}
+
+	return calculator.Start()
 }
 
-func NewCalculator() *Calculator {
+func NewCalculator(options *Options) *Calculator {
 	calc := new(Calculator)
+	calc.Options = options
 	calc.Log = log.NewLogrus()
 
+	// Keep stdout clean for the report, only warnings and errors are relevant
+	if options.Quiet {
+		calc.Log.Level = logrus.WarnLevel
+	}
+
 	return calc
 }
 
-func (c *Calculator) Start() {
-	projects = append(projects, c.getProjects("0:0:0")...)
+// Start runs the calculation and returns the process exit code
+func (c *Calculator) Start() int {
+	err := c.preflight()
+	switch err.(type) {
+	case nil:
+	case *authError, *configError:
+		c.Log.Error(err.Error())
+		return exitConfig
+	default:
+		c.Log.Error(err.Error())
+		return exitFailure
+	}
 
-	for _, project := range projects {
-		issues = append(issues, c.getIssues(project, "0:0:0")...)
+	return c.calculate(func(stats *Stats) error {
+		c.setPhase(phaseCrawling)
+		c.crawl(stats)
+		return nil
+	})
+}
+
+// newStats returns the stats a run collects into
+func (c *Calculator) newStats() *Stats {
+	// Only the spreadsheets and the terminal dashboard need the rows, the JSON
+	// report is built from running totals
+	stats := newStats(c.Options.Format == formatXLSX || c.Options.Command == "tui", c.Options.EventsMemory*1024*1024)
+
+	// Bursts are only found going through the events in order
+	stats.keepEvents = c.Options.collapsesBursts() && !c.Options.SkipMTBF
+
+	return stats
+}
+
+// compute builds the report out of the collected stats
+func (c *Calculator) compute(stats *Stats) (report Report, err error) {
+	if stats.keepEvents {
+		err = stats.collapseBursts(c.Options.BurstWindow)
+		if err != nil {
+			return report, fmt.Errorf("Can't collapse bursts: %v", err)
+		}
 	}
 
-	for _, issue := range issues {
-		events = append(events, c.getEvents(issue, "0:0:0")...)
+	return c.buildReport(stats), nil
+}
+
+// Merge reports on the datasets dumped by previous runs, e.g. one per shard
+func (c *Calculator) Merge(paths []string) int {
+	return c.calculate(func(stats *Stats) error {
+		c.setPhase(phaseReplaying)
+		return c.replay(paths, stats)
+	})
+}
+
+// calculate collects issues and events into stats, then writes the outputs
+// and returns the process exit code
+func (c *Calculator) calculate(collect func(stats *Stats) error) int {
+	var err error
+
+	stats := c.newStats()
+	defer stats.close()
+
+	c.mutex.Lock()
+	c.stats = stats
+	c.mutex.Unlock()
+
+	if c.Options.SignKey != "" {
+		c.signingKey, err = loadSigningKey(c.Options.SignKey)
+		if err != nil {
+			c.Log.Error(err.Error())
+			return exitConfig
+		}
+	}
+
+	var baseline map[string]interface{}
+
+	if c.Options.Baseline != "" {
+		baseline, err = c.loadBaseline(c.Options.Baseline)
+		if err != nil {
+			c.Log.Error(err.Error())
+			return exitConfig
+		}
+	}
+
+	// A bundle needs the dataset even when it isn't dumped
+	datasetPath := c.dumpPath()
+
+	if datasetPath == "" && c.Options.Bundle != "" {
+		dir, err := ioutil.TempDir("", "sentry-mttr-mtbf-bundle-")
+		if err != nil {
+			c.Log.Error(err.Error())
+			return exitFailure
+		}
+
+		defer os.RemoveAll(dir)
+
+		datasetPath = filepath.Join(dir, bundleDataset)
+	}
+
+	if datasetPath != "" {
+		var seal func(w io.Writer) (io.WriteCloser, error)
+		if c.Options.Encrypt {
+			seal = c.encryptStream
+		}
+
+		c.dataset, err = createDataset(datasetPath, seal)
+		if err != nil {
+			c.Log.Error(err.Error())
+			return exitFailure
+		}
+	}
+
+	err = collect(stats)
+
+	if c.dataset != nil {
+		closeErr := c.dataset.close()
+		if err == nil && closeErr != nil {
+			err = fmt.Errorf("Can't write dataset '%v': %v", datasetPath, closeErr)
+		}
+	}
+
+	if err != nil {
+		c.Log.Error(err.Error())
+		return exitFailure
+	}
+
+	if c.Options.Dump != "" {
+		c.addFileArtifact(datasetPath)
+	}
+
+	if c.isAborted() {
+		return exitConfig
+	}
+
+	c.setPhase(phaseWriting)
+	computeStart := time.Now()
+
+	report, err := c.compute(stats)
+	if err != nil {
+		c.Log.Error(err.Error())
+		return exitFailure
+	}
+
+	if baseline != nil {
+		report.Baseline, err = c.compareBaseline(report, baseline)
+		if err != nil {
+			c.Log.Error(err.Error())
+			return exitFailure
+		}
+	}
+
+	c.addTiming(timingCompute, computeStart, 0)
+	exportStart := time.Now()
+
+	if c.history == nil && c.Options.History != "" {
+		c.history = newHistory(c.Options.History)
+	}
+
+	if c.history != nil {
+		err = c.history.append(report)
+		if err != nil {
+			c.Log.Error(err.Error())
+			return exitFailure
+		}
+	}
+
+	switch c.Options.Format {
+	case formatJSON:
+		var buffer bytes.Buffer
+		c.writeReport(&buffer, report)
+
+		content := buffer.Bytes()
+
+		if c.Options.Encrypt {
+			content, err = c.encrypt(content)
+			if err != nil {
+				c.Log.Error(err.Error())
+				return exitFailure
+			}
+		}
+
+		// The terminal dashboard owns stdout
+		if c.Options.Command != "tui" {
+			os.Stdout.Write(content)
+			c.addArtifact(stdoutArtifact, content)
+		}
+	default:
+		if !c.Options.SkipMTTR {
+			c.saveActivitiesIntoXLSX(stats.Activities)
+		}
+
+		c.saveHeatmapIntoXLSX(report.Heatmap)
+		c.saveBurnDownIntoXLSX(report.PerProject)
+
+		if len(report.Diagnostics) > 0 {
+			c.saveDiagnosticsIntoXLSX(report.Diagnostics)
+		}
+
+		if !c.Options.SkipMTBF {
+			c.saveEventsIntoXLSX(stats.KeptEvents)
+		}
+	}
+
+	if c.signingKey != nil {
+		err = c.signManifest(report.Metadata)
+		if err != nil {
+			c.Log.Error(err.Error())
+			return exitFailure
+		}
+	}
+
+	if c.Options.Bundle != "" {
+		err = c.writeBundle(datasetPath, report)
+		if err != nil {
+			c.Log.Error(err.Error())
+			return exitFailure
+		}
+	}
+
+	c.publish(report)
+	c.addTiming(timingExport, exportStart, 0)
+
+	// The terminal dashboard owns the screen
+	if c.Options.Timings && c.Options.Command != "tui" {
+		c.writeTimings(os.Stderr)
+	}
+
+	c.mutex.Lock()
+	c.report, c.activities = &report, stats.Activities
+	c.mutex.Unlock()
+
+	c.setPhase(phaseDone)
+
+	return c.exitCode(report)
+}
+
+// crawl streams projects, issues and events into stats through a pipeline with
+// one stage per kind of request, each stage running with its own concurrency
+func (c *Calculator) crawl(stats *Stats) {
+	organizations, err := c.getOrganizations("0:0:0")
+	if err != nil && c.fetchFailed(err) {
+		return
+	}
+
+	c.advance("organizations", len(organizations))
+
+	queue := make(chan Organization, len(organizations))
+	batches := make(chan []Project)
+	listed := make(chan Issue)
+	detailed := make(chan Issue)
+
+	for _, organization := range organizations {
+		queue <- organization
+	}
+
+	close(queue)
+
+	go func() {
+		defer close(batches)
+
+		parallel(c.Options.ProjectsConcurrency, func() {
+			for organization := range queue {
+				fetched, err := c.getProjects(organization, "0:0:0")
+				if err != nil {
+					c.fetchFailed(err)
+				}
+
+				// Teams name the owners of the issues
+				teams, err := c.getTeams(organization, "0:0:0")
+				if err != nil {
+					c.fetchFailed(err)
+				}
+
+				c.addTeams(teams)
+
+				var projects []Project
+				for _, project := range fetched {
+					if c.inShard(project) {
+						projects = append(projects, project)
+					}
+				}
+
+				c.recordProjects(projects, stats)
+				c.advance("projects", len(projects))
+
+				for _, batch := range batchProjects(projects) {
+					batches <- batch
+				}
+			}
+		})
+	}()
+
+	go func() {
+		defer close(listed)
+
+		parallel(c.Options.IssuesConcurrency, func() {
+			for batch := range batches {
+				if c.isAborted() {
+					continue
+				}
+
+				err := c.getIssues(batch, "0:0:0", func(issue Issue) {
+					c.advance("issues", 1)
+					listed <- issue
+				})
+				if err != nil {
+					c.fetchFailed(err)
+				}
+			}
+		})
+	}()
+
+	// Only resolved and regressed issues need the detail call since their
+	// activity only feeds MTTR and the reopen rate, unless MTTD needs the first
+	// release of every issue
+	go func() {
+		defer close(detailed)
+
+		parallel(c.Options.DetailsConcurrency, func() {
+			for issue := range listed {
+				if c.isAborted() {
+					continue
+				}
+
+				if (issue.wasResolved() && !c.Options.SkipMTTR) || c.Options.MTTD {
+					detail, err := c.getIssue(issue.Id)
+					if err != nil {
+						c.fetchFailed(err)
+						continue
+					}
+
+					detail.Project = issue.Project
+					detail.Owners = issue.Owners
+					issue = detail
+
+					c.advance("details", 1)
+				}
+
+				if c.Options.MTTD || (c.Options.ClockStart == clockRelease && issue.wasResolved() && !c.Options.SkipMTTR) {
+					deployed, err := c.releasedAt(issue)
+					if err != nil {
+						c.fetchFailed(err)
+					}
+
+					if !deployed.IsZero() {
+						issue.ReleasedAt = deployed.Format(timeFormat)
+					}
+
+					if c.Options.MTTD {
+						issue.TimeToDetect = c.timeToDetect(issue, deployed)
+					}
+				}
+
+				detailed <- issue
+			}
+		})
+	}()
+
+	// Events only feed MTBF and are by far the most expensive phase
+	parallel(c.Options.EventsConcurrency, func() {
+		for issue := range detailed {
+			if c.isAborted() {
+				continue
+			}
+
+			issue.Owner = c.issueOwner(issue)
+			environments := make(map[string]int)
+
+			if !c.Options.SkipMTBF {
+				err := c.getEvents(issue, "0:0:0", c.Options.MaxEventsPerIssue, func(event Event) {
+					environments[event.environment()]++
+					c.advance("events", 1)
+					c.recordEvent(issue, event, stats)
+				})
+				if err != nil {
+					c.fetchFailed(err)
+				}
+			}
+
+			issue.Environment = mostFrequentEnvironment(environments)
+
+			c.Log.Debug(fmt.Sprintf("%# v", pretty.Formatter(issue)))
+
+			c.recordIssue(issue, stats)
+		}
+	})
+}
+
+// fetchFailed records a failed fetch and tells whether the run must be aborted,
+// it's safe to call from concurrent fetches
+func (c *Calculator) fetchFailed(err error) (abort bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := err.(*authError); ok {
+		c.Log.Error(err.Error())
+		c.aborted = true
+		return true
 	}
 
-	c.sortEventsBasedOnTime()
+	c.Log.Warn(err.Error())
+	c.failures++
 
-	c.Log.Debug("====================")
-	c.Log.Debug("Dataset")
-	c.Log.Debug(fmt.Sprintf("%# v", pretty.Formatter(issues)))
-	c.Log.Debug("====================")
-	c.Log.Debug(fmt.Sprintf("%# v", pretty.Formatter(events)))
-	c.Log.Debug("====================")
+	return false
+}
 
-	mttr := c.calcMTTR(issues)
-	c.Log.Info(fmt.Sprintf("MTTR: %.0f seconds", mttr))
+func (c *Calculator) isAborted() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 
-	mtbf := c.calcMTBF(events)
-	c.Log.Info(fmt.Sprintf("MTBF: %.0f seconds", mtbf))
+	return c.aborted
+}
 
-	c.saveActivitiesIntoXLSX(activities)
-	c.saveEventsIntoXLSX(eventsMTBF)
+// exitCode reports partial data first, since thresholds on incomplete data can't be trusted
+func (c *Calculator) exitCode(report Report) int {
+	if c.failures > 0 {
+		c.Log.Warn(fmt.Sprintf("%d fetches failed, metrics are based on partial data", c.failures))
+		return exitPartial
+	}
+
+	if report.MTTR != nil && c.Options.MaxMTTR > 0 && *report.MTTR > c.Options.MaxMTTR.Seconds() {
+		c.Log.Warn(fmt.Sprintf("MTTR of %.0f seconds is above %v", *report.MTTR, c.Options.MaxMTTR))
+		return exitThreshold
+	}
+
+	if report.MTBF != nil && c.Options.MinMTBF > 0 && *report.MTBF < c.Options.MinMTBF.Seconds() {
+		c.Log.Warn(fmt.Sprintf("MTBF of %.0f seconds is below %v", *report.MTBF, c.Options.MinMTBF))
+		return exitThreshold
+	}
+
+	return exitSuccess
 }
 
-func (c *Calculator) sortEventsBasedOnTime() {
+// environment is the value of the environment tag of the event
+func (e Event) environment() string {
+	for _, tag := range e.Tags {
+		if tag.Key == "environment" && tag.Value != "" {
+			return tag.Value
+		}
+	}
+
+	return noEnvironment
+}
+
+// mostFrequentEnvironment attributes an issue to the environment most of its
+// events happened in, issues don't carry the tag themselves
+func mostFrequentEnvironment(environments map[string]int) string {
+	environment := noEnvironment
+	count := 0
+
+	for name, occurrences := range environments {
+		if occurrences > count || (occurrences == count && name < environment) {
+			environment = name
+			count = occurrences
+		}
+	}
+
+	return environment
+}
+
+func sortEventsBasedOnTime(events []Event) {
 	slice.Sort(events[:], func(i, j int) bool {
 		return events[i].DateCreated < events[j].DateCreated
 	})
 }
 
-func (c *Calculator) saveEventsIntoXLSX(events []ComputedEvent) {
+func (c *Calculator) saveEventsIntoXLSX(events *eventStore) {
 	var file *xlsx.File
 	var sheet *xlsx.Sheet
 	var row *xlsx.Row
 	var cell *xlsx.Cell
 	var err error
 
-	totalEvents := len(events)
+	totalEvents := 0
 	outputFile := fmt.Sprintf("mtbf_%v", sheetName)
 
-	c.Log.Info(fmt.Sprintf("Registered %v events", totalEvents))
-	c.Log.Info(fmt.Sprintf("Output file '%v'", outputFile))
-
 	file = xlsx.NewFile()
 	sheet, err = file.AddSheet("MTBF")
 	if err != nil {
@@ -152,26 +735,44 @@ func (c *Calculator) saveEventsIntoXLSX(events []ComputedEvent) {
 
 	row = sheet.AddRow()
 	cell = row.AddCell()
-	cell.Value = "Event Id"
+	cell.Value = c.text("heading.event_id")
+	cell = row.AddCell()
+	cell.Value = c.text("heading.created_at")
 	cell = row.AddCell()
-	cell.Value = "Created At"
+	cell.Value = c.text("heading.environment")
 	cell = row.AddCell()
-	cell.Value = "Duration In Seconds"
+	cell.Value = c.text("heading.duration")
+
+	err = c.calcTimeBetweenFailures(events, func(event ComputedEvent) {
+		totalEvents++
+
+		// Excel can't open sheets beyond this, the header takes a row
+		if totalEvents == maxSheetRows {
+			c.Log.Warn(fmt.Sprintf("Only the first %d events fit in '%v'", maxSheetRows-1, outputFile))
+		}
+
+		if totalEvents >= maxSheetRows {
+			return
+		}
 
-	for _, event := range events {
 		row = sheet.AddRow()
 		cell = row.AddCell()
 		cell.Value = event.Event.Id
 		cell = row.AddCell()
-		cell.Value = event.Event.DateCreated
+		cell.Value = c.formatDateCell(event.Event.DateCreated)
 		cell = row.AddCell()
-		cell.Value = fmt.Sprintf("%.0f", event.Duration)
-	}
-
-	err = file.Save(outputFile)
+		cell.Value = event.Event.environment()
+		cell = row.AddCell()
+		setSecondsCell(cell, event.Duration)
+	})
 	if err != nil {
 		panic(err.Error())
 	}
+
+	c.Log.Info(fmt.Sprintf("Registered %v events", totalEvents))
+	c.Log.Info(fmt.Sprintf("Output file '%v'", outputFile))
+
+	c.saveXLSX(file, outputFile)
 }
 
 func (c *Calculator) saveActivitiesIntoXLSX(activities []ComputedActivity) {
@@ -195,13 +796,26 @@ func (c *Calculator) saveActivitiesIntoXLSX(activities []ComputedActivity) {
 
 	row = sheet.AddRow()
 	cell = row.AddCell()
-	cell.Value = "Issue Id"
+	cell.Value = c.text("heading.issue_id")
+	cell = row.AddCell()
+	cell.Value = c.text("heading.issue_status")
+	cell = row.AddCell()
+	cell.Value = c.text("heading.project_name")
+	cell = row.AddCell()
+	cell.Value = c.text("heading.environment")
+	cell = row.AddCell()
+	cell.Value = c.text("heading.owner")
 	cell = row.AddCell()
-	cell.Value = "Issue Status"
+	cell.Value = c.text("heading.time_to_resolve")
 	cell = row.AddCell()
-	cell.Value = "Project Name"
+	cell.Value = c.text("heading.assigned_to_resolved")
 	cell = row.AddCell()
-	cell.Value = "Time to Resolve In Seconds"
+	cell.Value = c.text("heading.mttr_target")
+
+	// Issues that took longer than the target of their project stand out
+	breached := xlsx.NewStyle()
+	breached.Fill = *xlsx.NewFill("solid", "FFFFC7CE", "FFFFC7CE")
+	breached.ApplyFill = true
 
 	for _, activity := range activities {
 		row = sheet.AddRow()
@@ -212,288 +826,590 @@ func (c *Calculator) saveActivitiesIntoXLSX(activities []ComputedActivity) {
 		cell = row.AddCell()
 		cell.Value = activity.Issue.Project.Name
 		cell = row.AddCell()
-		cell.Value = fmt.Sprintf("%.0f", activity.Duration)
+		cell.Value = activity.Issue.Environment
+		cell = row.AddCell()
+		cell.Value = activity.Issue.Owner
+		cell = row.AddCell()
+		setSecondsCell(cell, activity.Duration)
+		cell = row.AddCell()
+
+		if activity.Assignments > 0 {
+			setSecondsCell(cell, activity.AssignedDuration)
+		}
+		cell = row.AddCell()
+
+		if activity.Target > 0 {
+			setSecondsCell(cell, activity.Target)
+		}
+
+		if activity.Breached {
+			for _, cell := range row.Cells {
+				cell.SetStyle(breached)
+			}
+		}
 	}
 
-	err = file.Save(outputFile)
+	c.saveXLSX(file, outputFile)
+}
+
+// calcMTBF accounts an event of an issue into stats
+func (c *Calculator) calcMTBF(issue Issue, event Event, stats *Stats) {
+	date, err := time.Parse(timeFormat, event.DateCreated)
 	if err != nil {
-		panic(err.Error())
+		c.Log.Warn(fmt.Sprintf("Event #%v dropped, invalid date: %v", event.Id, err))
+		return
 	}
+
+	stats.addEvent(issue, event, date)
 }
 
-func (c *Calculator) calcMTBF(events []Event) (mtbf float64) {
-	lastTime := ""
+// calcTimeBetweenFailures emits every event, in order, with the time since
+// the previous one, the first event has nothing to be compared with. When
+// bursts are collapsed only the events starting a failure are emitted, with
+// the time since the previous failure, so they average to the MTBF
+func (c *Calculator) calcTimeBetweenFailures(events *eventStore, emit func(ComputedEvent)) error {
+	var lastEventDate, lastFailureDate time.Time
+	window := c.Options.BurstWindow
+
+	return events.each(func(event Event) {
+		currentEventDate, err := time.Parse(timeFormat, event.DateCreated)
+		if err != nil {
+			panic(err)
+		}
 
-	for _, event := range events {
-		if lastTime != "" {
-			lastEventDate, err := time.Parse(timeFormat, lastTime)
-			if err != nil {
-				panic(err)
-			}
+		defer func() {
+			lastEventDate = currentEventDate
+		}()
 
-			currentEventDate, err := time.Parse(timeFormat, event.DateCreated)
-			if err != nil {
-				panic(err)
-			}
+		if lastEventDate.IsZero() {
+			c.Log.Debug(fmt.Sprintf("Event #%v is new, not computed", event.Id))
+			lastFailureDate = currentEventDate
+			return
+		}
 
+		if !c.Options.collapsesBursts() {
 			duration := currentEventDate.Sub(lastEventDate).Seconds()
-			eventsMTBF = append(eventsMTBF, ComputedEvent{Event: event, Duration: duration})
+			emit(ComputedEvent{Event: event, Duration: duration})
 
 			c.Log.Debug(fmt.Sprintf("Event #%v took %.0f seconds to appear", event.Id, duration))
-		} else {
-			c.Log.Debug(fmt.Sprintf("Event #%v is new, not computed", event.Id))
+			return
 		}
 
-		lastTime = event.DateCreated
+		if currentEventDate.Sub(lastEventDate) <= window {
+			c.Log.Debug(fmt.Sprintf("Event #%v is part of the previous failure, not computed", event.Id))
+			return
+		}
+
+		duration := currentEventDate.Sub(lastFailureDate).Seconds()
+		lastFailureDate = currentEventDate
+
+		emit(ComputedEvent{Event: event, Duration: duration})
+
+		c.Log.Debug(fmt.Sprintf("Event #%v started a failure %.0f seconds after the previous one", event.Id, duration))
+	})
+}
+
+// isResolution tells whether an activity resolved an issue, whichever way
+func isResolution(activityType string) bool {
+	return strings.HasPrefix(activityType, "set_resolved")
+}
+
+// isRepair tells whether an activity stops the repair clock, Sentry resolves
+// issues silent for a while by itself and those can be left out
+func (c *Calculator) isRepair(activityType string) bool {
+	if activityType == autoResolved && c.Options.ExcludeAutoResolved {
+		return false
 	}
 
-	totalIterations, totalTime := c.calcMediumTimeForMTTR()
-	mtbf = totalTime / totalIterations
+	return matchActivity(c.Options.RepairEnd, activityType)
+}
 
-	return
+// isRepairStart tells whether an activity starts the repair clock
+func (c *Calculator) isRepairStart(activityType string) bool {
+	return matchActivity(c.Options.RepairStart, activityType)
 }
 
-func (c *Calculator) calcMediumTimeForMTTR() (totalIterations float64, totalTime float64) {
-	totalIterations = 0
-	totalTime = 0
+// matchActivity tells whether an activity type matches any of the patterns
+func matchActivity(patterns []string, activityType string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, activityType); matched {
+			return true
+		}
+	}
 
-	for _, event := range eventsMTBF {
-		totalIterations++
-		totalTime += event.Duration
+	return false
+}
+
+// wasResolved tells whether an issue has been resolved at some point, either
+// it still is or it regressed since
+func (i Issue) wasResolved() bool {
+	return i.Status != "unresolved" || i.Substatus == "regressed"
+}
+
+// calcReopens accounts whether a resolved issue regressed afterwards
+func (c *Calculator) calcReopens(issue Issue, stats *Stats) {
+	resolved, reopened := false, false
+
+	// Sentry lists the newest activity first
+	for i := len(issue.Activity)-1; i >= 0; i-- {
+		switch issue.Activity[i].Type {
+		case "set_regression":
+			reopened = reopened || resolved
+		default:
+			resolved = resolved || isResolution(issue.Activity[i].Type)
+		}
 	}
 
-	return
+	if !resolved {
+		return
+	}
+
+	if reopened {
+		c.Log.Debug(fmt.Sprintf("Issue #%v was reopened", issue.Id))
+	}
+
+	stats.addResolvedIssue(issue, reopened)
 }
 
-func (c *Calculator) calcMTTR(issues []Issue) (mttr float64) {
-	var totalIterations float64
-	var totalTime float64
+// calcMTTR accounts the repairs of an issue into stats
+func (c *Calculator) calcMTTR(issue Issue, stats *Stats) {
+	c.Log.Debug(fmt.Sprintf("Looking at issue #%v", issue.Id))
 
-	totalIssues := len(issues)
+	if issue.Status == "unresolved" {
+		c.Log.Debug(fmt.Sprintf("Issue #%v dropped, unresolved", issue.Id))
+		return
+	}
 
-	c.Log.Debug(fmt.Sprintf("Found %d issues", totalIssues))
+	// Targets hold for every repair, an issue breaches when any one is longer
+	var longest float64
 
-	for _, issue := range issues {
-		c.Log.Debug(fmt.Sprintf("Looking at issue #%v", issue.Id))
+	totalIterations, totalTime, assignments, assignedTime := c.calcTimeToRepair(issue, stats, func(activityType string, duration float64) {
+		stats.addSourceRepair(resolutionSource(activityType), duration)
 
-		if issue.Status == "unresolved" {
-			c.Log.Debug(fmt.Sprintf("Issue #%v dropped, unresolved", issue.Id))
-		} else {
-			auxTotalIterations, auxTotalTime := c.calcTimeToRepair(issue.Activity)
+		if duration > longest {
+			longest = duration
+		}
+	})
+	c.calcResolutions(issue, stats)
+
+	// The activity isn't exported, don't hold on to it
+	issue.Activity = nil
 
-			activities = append(activities, ComputedActivity{Issue: issue, Duration: auxTotalTime})
+	activity := ComputedActivity{Issue: issue, Duration: totalTime, Assignments: assignments, AssignedDuration: assignedTime}
 
-			totalIterations += auxTotalIterations
-			totalTime += auxTotalTime
+	target, ok := c.mttrTarget(issue.Project)
+	if ok && totalIterations > 0 {
+		activity.Target = target.Seconds()
+		activity.Breached = longest > activity.Target
+
+		if activity.Breached {
+			c.Log.Debug(fmt.Sprintf("Issue #%v breached its MTTR target of %v", issue.Id, target))
 		}
 	}
 
-	mttr = totalTime / totalIterations
-
-	return
+	stats.addRepairs(activity, totalIterations, totalTime)
 }
 
-func (c *Calculator) calcTimeToRepair(activities []Activity) (totalIterations float64, totalTime float64) {
+// calcTimeToRepair sums the repairs in the activity of an issue, each going
+// from a start to the next repair. A regression or an auto resolution that
+// isn't a repair closes the interval without counting it. The assignment
+// inside an interval gives the time from assignment to resolution, so both
+// cover the same repairs.
+// repaired is called with the activity that resolved each of them
+func (c *Calculator) calcTimeToRepair(issue Issue, stats *Stats, repaired func(activityType string, duration float64)) (totalIterations float64, totalTime float64, totalAssignments float64, totalAssigned float64) {
+	activities := issue.Activity
+
 	c.Log.Debug(fmt.Sprintf("Looking at %v activities", len(activities)))
 
 	// We need to make it as reverse because of Sentry data
 	for i := len(activities)-1; i >= 0; i-- {
 		c.Log.Debug(fmt.Sprintf("Activity #%s is '%s'", activities[i].Id, activities[i].Type))
 
-		if activities[i].Type == "first_seen" {
-			startTime, err := time.Parse(timeFormat, activities[i].DateCreated)
-			if err != nil {
-				panic(err)
+		if !c.isRepairStart(activities[i].Type) {
+			continue
+		}
+
+		startTime, err := time.Parse(timeFormat, activities[i].DateCreated)
+		if err != nil {
+			c.Log.Warn(fmt.Sprintf("Activity #%s dropped, invalid date: %v", activities[i].Id, err))
+			continue
+		}
+
+		// The clock may start when the bad code shipped instead
+		if activities[i].Type == "first_seen" && c.Options.ClockStart == clockRelease {
+			releasedAt, err := time.Parse(timeFormat, issue.ReleasedAt)
+			if err == nil && releasedAt.Before(startTime) {
+				startTime = releasedAt
+			} else {
+				c.Log.Debug(fmt.Sprintf("Issue #%v keeps its first seen clock start, its release isn't known", issue.Id))
 			}
+		}
 
-			i--
+		// Look for the end of the interval, keeping who got assigned on the way
+		var assignedTime time.Time
+		end := -1
 
-			if activities[i].Type == "set_resolved" || activities[i].Type == "set_regression" {
-				c.Log.Debug(fmt.Sprintf("Activity #%s resolved in sequence", activities[i].Id))
+		for j := i-1; j >= 0; j-- {
+			activityType := activities[j].Type
 
-				endTime, err := time.Parse(timeFormat, activities[i].DateCreated)
+			if c.isRepair(activityType) || activityType == "set_regression" || activityType == autoResolved {
+				end = j
+				break
+			}
+
+			switch activityType {
+			case "assigned":
+				if !assignedTime.IsZero() {
+					continue
+				}
+
+				date, err := time.Parse(timeFormat, activities[j].DateCreated)
 				if err != nil {
-					panic(err)
+					c.Log.Warn(fmt.Sprintf("Activity #%s dropped, invalid date: %v", activities[j].Id, err))
+					continue
 				}
 
-				duration := endTime.Sub(startTime).Seconds()
+				assignedTime = date
+			case "unassigned":
+				assignedTime = time.Time{}
+			}
+		}
 
-				totalIterations++
-				totalTime += duration
+		if end < 0 {
+			c.Log.Debug(fmt.Sprintf("Issue #%v has a repair still open", issue.Id))
+			break
+		}
 
-				c.Log.Debug(fmt.Sprintf("Took %.0f seconds to resolve", duration))
+		i = end
+		endType := activities[end].Type
 
-				if (activities[i].Type == "set_regression") {
-					i++
-				}
-			}
+		// Regressions and auto resolutions close the interval uncounted, they
+		// may start the clock again
+		if !c.isRepair(endType) {
+			c.Log.Debug(fmt.Sprintf("Activity #%s closed the repair uncounted, '%s'", activities[end].Id, endType))
+			i++
+			continue
+		}
+
+		c.Log.Debug(fmt.Sprintf("Activity #%s resolved the repair", activities[end].Id))
+
+		endTime, err := time.Parse(timeFormat, activities[end].DateCreated)
+		if err != nil {
+			c.Log.Warn(fmt.Sprintf("Activity #%s dropped, invalid date: %v", activities[end].Id, err))
+			continue
 		}
+
+		duration := endTime.Sub(startTime).Seconds()
+
+		if !c.checkDuration(issue, durationRepair, duration, stats) {
+			continue
+		}
+
+		totalIterations++
+		totalTime += duration
+
+		c.Log.Debug(fmt.Sprintf("Took %.0f seconds to resolve", duration))
+
+		repaired(endType, duration)
+
+		if assignedTime.IsZero() {
+			continue
+		}
+
+		assigned := endTime.Sub(assignedTime).Seconds()
+
+		if !c.checkDuration(issue, durationAssigned, assigned, stats) {
+			continue
+		}
+
+		totalAssignments++
+		totalAssigned += assigned
+
+		c.Log.Debug(fmt.Sprintf("Took %.0f seconds to resolve after assignment", assigned))
 	}
 
-	return totalIterations, totalTime
+	return totalIterations, totalTime, totalAssignments, totalAssigned
 }
 
-func (c *Calculator) requestEvents(issue Issue, cursor string) (resp *http.Response, err error) {
+// request sends a GET to Sentry, every attempt is timed as part of phase
+// while the waits between them aren't
+func (c *Calculator) request(phase string, uri string) (resp *http.Response, err error) {
 	client := &http.Client{}
-	uri := fmt.Sprintf("%s0/issues/%s/events/?query=&cursor=%s", sentryURL, issue.Id, cursor)
 
 	c.Log.Debug(fmt.Sprintf("GET %s", uri))
 
-	req, _ := http.NewRequest("GET", uri, nil)
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", sentryToken))
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return
+	}
+
+	for name, value := range c.Options.SentryHeaders {
+		req.Header.Set(name, value)
+	}
 
-	resp, err = client.Do(req)
+	req.Header.Set("User-Agent", c.Options.UserAgent)
 
-	if err != nil {
-		panic("Error while fetch data.")
+	// A refused or rate limited token is retried while its source can replace
+	// it or wait for its quota, a 403 only fails this request
+	var waited time.Duration
+
+	for attempt := 0; ; attempt++ {
+		token, err := sentryTokens.token()
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		if attempt > 0 {
+			c.addRetry(phase, waited)
+			waited = 0
+		}
+
+		c.advance("requests", 1)
+		start := time.Now()
+
+		resp, err = client.Do(req)
+		c.addTiming(phase, start, 1)
+
+		if err != nil {
+			return nil, fmt.Errorf("Error while fetching %s: %v", uri, err)
+		}
+
+		if resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusTooManyRequests {
+			break
+		}
+
+		retry, wait := sentryTokens.refused(token, resp)
+
+		if retry && wait > 0 {
+			resp.Body.Close()
+			c.Log.Warn(fmt.Sprintf("GET %s answered HTTP %d with every token rate limited, retrying in %v", uri, resp.StatusCode, wait.Round(time.Second)))
+			time.Sleep(wait)
+			waited = wait
+			continue
+		}
+
+		if retry {
+			resp.Body.Close()
+			c.Log.Warn(fmt.Sprintf("GET %s answered HTTP %d, retrying with another token", uri, resp.StatusCode))
+			continue
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			break
+		}
+
+		resp.Body.Close()
+		return nil, &authError{URI: uri, StatusCode: resp.StatusCode}
+	}
+
+	if resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s returned HTTP %d", uri, resp.StatusCode)
 	}
 
 	return
 }
 
-func (c *Calculator) getEvents(issue Issue, cursor string) (events []Event) {
-	resp, _ := c.requestEvents(issue, cursor)
+// readPage decodes a response into v and returns the cursor of the next page, if any
+func (c *Calculator) readPage(resp *http.Response, v interface{}) (cursor string, more bool, err error) {
+	defer resp.Body.Close()
 
 	b, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		panic(err)
+		return
 	}
 
-	err = json.Unmarshal(b, &events)
+	err = json.Unmarshal(b, v)
 	if err != nil {
-		panic(err)
+		return "", false, fmt.Errorf("Invalid response from %s: %v", resp.Request.URL, err)
 	}
 
-	link := resp.Header.Get("Link")
-	links := linkheader.Parse(link)
-	nextPage := links[1].Params
-
-	if nextPage["results"] == "true" {
-		c.getEvents(issue, nextPage["cursor"])
+	for _, link := range linkheader.Parse(resp.Header.Get("Link")).FilterByRel("next") {
+		return link.Param("cursor"), link.Param("results") == "true", nil
 	}
 
 	return
 }
 
-func (c *Calculator) requestProjects(cursor string) (resp *http.Response, err error) {
-	client := &http.Client{}
-	uri := fmt.Sprintf("%s0/projects/?query=&cursor=%s", sentryURL, cursor)
-
-	c.Log.Debug(fmt.Sprintf("GET %s", uri))
-
-	req, _ := http.NewRequest("GET", uri, nil)
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", sentryToken))
+func (c *Calculator) requestEvents(issue Issue, cursor string) (resp *http.Response, err error) {
+	uri := fmt.Sprintf("%s0/issues/%s/events/?query=&cursor=%s", sentryURL, issue.Id, cursor)
 
-	resp, err = client.Do(req)
+	return c.request(timingEvents, uri)
+}
 
+// getEvents streams the events of an issue to emit, newest first, stopping
+// after limit events when limit is positive
+func (c *Calculator) getEvents(issue Issue, cursor string, limit int, emit func(Event)) (err error) {
+	resp, err := c.requestEvents(issue, cursor)
 	if err != nil {
-		panic("Error while fetch data.")
+		return
 	}
 
-	return
-}
-
-func (c *Calculator) getProjects(cursor string) (projects []Project) {
-	resp, _ := c.requestProjects(cursor)
+	var events []Event
 
-	b, err := ioutil.ReadAll(resp.Body)
+	nextCursor, more, err := c.readPage(resp, &events)
 	if err != nil {
-		panic(err)
+		return
 	}
 
-	err = json.Unmarshal(b, &projects)
-	if err != nil {
-		panic(err)
+	if limit > 0 && len(events) >= limit {
+		c.Log.Debug(fmt.Sprintf("Issue #%v reached the cap of events", issue.Id))
+		events = events[:limit]
+		more = false
 	}
 
-	link := resp.Header.Get("Link")
-	links := linkheader.Parse(link)
-	nextPage := links[1].Params
-
-	if nextPage["results"] == "true" {
-		c.getProjects(nextPage["cursor"])
+	for _, event := range events {
+		emit(event)
 	}
 
-	return
-}
+	if !more {
+		return
+	}
 
-func (c *Calculator) requestIssues(project Project, cursor string) (resp *http.Response, err error) {
-	client := &http.Client{}
-	uri := fmt.Sprintf("%s0/projects/%s/%s/issues/?query=&cursor=%s", sentryURL, project.Organization.Slug, project.Slug, cursor)
+	if limit > 0 {
+		limit -= len(events)
+	}
 
-	c.Log.Debug(fmt.Sprintf("GET %s", uri))
+	return c.getEvents(issue, nextCursor, limit, emit)
+}
 
-	req, _ := http.NewRequest("GET", uri, nil)
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", sentryToken))
+func (c *Calculator) requestOrganizations(cursor string) (resp *http.Response, err error) {
+	uri := fmt.Sprintf("%s0/organizations/?member=1&cursor=%s", sentryURL, cursor)
 
-	resp, err = client.Do(req)
+	return c.request(timingProjects, uri)
+}
 
+func (c *Calculator) getOrganizations(cursor string) (organizations []Organization, err error) {
+	resp, err := c.requestOrganizations(cursor)
 	if err != nil {
-		panic("Error while fetch data.")
+		return
+	}
+
+	nextCursor, more, err := c.readPage(resp, &organizations)
+	if err != nil || !more {
+		return
 	}
 
+	nextOrganizations, err := c.getOrganizations(nextCursor)
+	organizations = append(organizations, nextOrganizations...)
+
 	return
 }
 
-func (c *Calculator) getIssues(project Project, cursor string) (issues []Issue) {
-	resp, _ := c.requestIssues(project, cursor)
-	currentIssues := []Issue{}
+func (c *Calculator) requestProjects(organization Organization, cursor string) (resp *http.Response, err error) {
+	uri := fmt.Sprintf("%s0/organizations/%s/projects/?query=&cursor=%s", sentryURL, organization.Slug, cursor)
 
-	b, err := ioutil.ReadAll(resp.Body)
+	return c.request(timingProjects, uri)
+}
+
+func (c *Calculator) getProjects(organization Organization, cursor string) (projects []Project, err error) {
+	resp, err := c.requestProjects(organization, cursor)
 	if err != nil {
-		panic(err)
+		return
 	}
 
-	err = json.Unmarshal(b, &currentIssues)
+	nextCursor, more, err := c.readPage(resp, &projects)
 	if err != nil {
-		panic(err)
+		return
+	}
+
+	for i := range projects {
+		projects[i].Organization = organization
 	}
 
-	for _, row := range currentIssues {
-		issues = append(issues, c.getIssue(row.Id))
+	if !more {
+		return
 	}
 
-	link := resp.Header.Get("Link")
-	links := linkheader.Parse(link)
-	nextPage := links[1].Params
+	nextProjects, err := c.getProjects(organization, nextCursor)
+	projects = append(projects, nextProjects...)
+
+	return
+}
+
+// batchProjects splits the projects of an organization in batches, since
+// issues are listed once per organization with a project filter
+func batchProjects(projects []Project) (batches [][]Project) {
+	for len(projects) > projectsPerIssuesRequest {
+		batches = append(batches, projects[:projectsPerIssuesRequest])
+		projects = projects[projectsPerIssuesRequest:]
+	}
 
-	if nextPage["results"] == "true" {
-		c.getIssues(project, nextPage["cursor"])
+	if len(projects) > 0 {
+		batches = append(batches, projects)
 	}
 
 	return
 }
 
-func (c *Calculator) getIssue(id string) (issue Issue) {
-	resp, _ := c.requestIssue(id)
+// The organization issues endpoint lists 25 issues a page unless told, 100 at most
+const issuesPageSize = 100
 
-	b, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		panic(err)
+func (c *Calculator) requestIssues(projects []Project, cursor string) (resp *http.Response, err error) {
+	query := url.Values{}
+	query.Set("query", "")
+	query.Set("cursor", cursor)
+	query.Set("expand", "owners")
+	query.Set("limit", fmt.Sprintf("%d", issuesPageSize))
+
+	// Without a range Sentry only lists the issues of the last 14 days
+	query.Set("statsPeriod", c.Options.Since)
+
+	for _, project := range projects {
+		query.Add("project", project.Id)
 	}
 
-	err = json.Unmarshal(b, &issue)
+	uri := fmt.Sprintf("%s0/organizations/%s/issues/?%s", sentryURL, projects[0].Organization.Slug, query.Encode())
+
+	return c.request(timingIssues, uri)
+}
+
+// getIssues streams the issues of projects from the same organization to emit
+func (c *Calculator) getIssues(projects []Project, cursor string, emit func(Issue)) (err error) {
+	resp, err := c.requestIssues(projects, cursor)
 	if err != nil {
-		panic(err)
+		return
 	}
 
-	return
-}
+	var issues []Issue
 
-func (c *Calculator) requestIssue(id string) (resp *http.Response, err error) {
-	client := &http.Client{}
-	uri := fmt.Sprintf("%s0/issues/%s/", sentryURL, id)
+	nextCursor, more, err := c.readPage(resp, &issues)
+	if err != nil {
+		return
+	}
 
-	c.Log.Debug(fmt.Sprintf("GET %s", uri))
+	projectsById := make(map[string]Project)
+	for _, project := range projects {
+		projectsById[project.Id] = project
+	}
+
+	for _, issue := range issues {
+		issue.Project = projectsById[issue.Project.Id]
+		emit(issue)
+	}
 
-	req, _ := http.NewRequest("GET", uri, nil)
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", sentryToken))
+	if !more {
+		return
+	}
 
-	resp, err = client.Do(req)
+	return c.getIssues(projects, nextCursor, emit)
+}
 
+func (c *Calculator) getIssue(id string) (issue Issue, err error) {
+	resp, err := c.requestIssue(id)
 	if err != nil {
-		panic("Error while fetch data.")
+		return
 	}
 
+	_, _, err = c.readPage(resp, &issue)
+
 	return
 }
+
+func (c *Calculator) requestIssue(id string) (resp *http.Response, err error) {
+	uri := fmt.Sprintf("%s0/issues/%s/", sentryURL, id)
+
+	return c.request(timingDetails, uri)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func testCalculator(options *Options) *Calculator {
+	if options.RepairStart == nil {
+		options.RepairStart = []string{"first_seen"}
+	}
+
+	if options.RepairEnd == nil {
+		options.RepairEnd = []string{"set_resolved*"}
+	}
+
+	c := NewCalculator(options)
+	c.Log.Level = logrus.ErrorLevel
+
+	return c
+}
+
+// activities lists activity types at the given hours newest first, as Sentry does
+func activities(types []string, hours []int) (list []Activity) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := len(types) - 1; i >= 0; i-- {
+		date := start.Add(time.Duration(hours[i]) * time.Hour)
+		list = append(list, Activity{Id: types[i], DateCreated: date.Format(timeFormat), Type: types[i]})
+	}
+
+	return
+}
+
+func TestCalcTimeToRepair(t *testing.T) {
+	cases := []struct {
+		name		string
+		repairStart	[]string
+		types		[]string
+		hours		[]int
+		repairs		float64
+		repairTime	float64
+		assignments	float64
+		assignedTime	float64
+		sources		[]string
+	}{
+		{
+			name: "assigned before the resolution",
+			types: []string{"first_seen", "assigned", "set_resolved"},
+			hours: []int{0, 1, 2},
+			repairs: 1, repairTime: 7200, assignments: 1, assignedTime: 3600,
+			sources: []string{"set_resolved"},
+		},
+		{
+			// The regression closes the first interval, it isn't a repair
+			name: "regression in the middle",
+			types: []string{"first_seen", "set_regression", "set_resolved"},
+			hours: []int{0, 1, 3},
+		},
+		{
+			name: "regression starting the clock again",
+			repairStart: []string{"first_seen", "set_regression"},
+			types: []string{"first_seen", "assigned", "set_regression", "assigned", "set_resolved_in_release"},
+			hours: []int{0, 1, 2, 4, 5},
+			repairs: 1, repairTime: 10800, assignments: 1, assignedTime: 3600,
+			sources: []string{"set_resolved_in_release"},
+		},
+		{
+			name: "regression after a repair",
+			repairStart: []string{"first_seen", "set_regression"},
+			types: []string{"first_seen", "set_resolved", "set_regression", "unassigned", "set_resolved"},
+			hours: []int{0, 2, 3, 4, 7},
+			repairs: 2, repairTime: 7200 + 14400,
+			sources: []string{"set_resolved", "set_resolved"},
+		},
+		{
+			name: "still open",
+			types: []string{"first_seen", "assigned"},
+			hours: []int{0, 1},
+		},
+	}
+
+	for _, c := range cases {
+		calculator := testCalculator(&Options{RepairStart: c.repairStart})
+		stats := newStats(false, 1024*1024)
+
+		var sources []string
+
+		issue := Issue{Id: "1", Activity: activities(c.types, c.hours)}
+		repairs, repairTime, assignments, assignedTime := calculator.calcTimeToRepair(issue, stats, func(activityType string, duration float64) {
+			sources = append(sources, activityType)
+		})
+
+		if repairs != c.repairs || repairTime != c.repairTime {
+			t.Errorf("%s: got %v repairs in %v seconds, want %v in %v", c.name, repairs, repairTime, c.repairs, c.repairTime)
+		}
+
+		if assignments != c.assignments || assignedTime != c.assignedTime {
+			t.Errorf("%s: got %v assignments in %v seconds, want %v in %v", c.name, assignments, assignedTime, c.assignments, c.assignedTime)
+		}
+
+		// Every counted repair is reported to the resolution sources
+		if len(sources) != len(c.sources) || float64(len(sources)) != repairs {
+			t.Errorf("%s: repaired by %v, want %v", c.name, sources, c.sources)
+		}
+
+		stats.close()
+	}
+}
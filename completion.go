@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Commands understood by run, used by the completion scripts
+var commands = []string{"completion", "decrypt", "merge", "serve", "tui", "verify", "version"}
+
+// Values offered when completing the argument of a flag
+var flagValues = map[string][]string{
+	"clock-start": {clockActivity, clockRelease},
+	"format": {formatXLSX, formatJSON},
+	"lang": languages,
+	"locale": localeNames(),
+	"out-of-bounds": {boundsFlag, boundsExclude},
+}
+
+// Flags whose argument is a path
+var fileFlags = map[string]bool{
+	"baseline": true,
+	"bundle": true,
+	"dump": true,
+	"history": true,
+	"key-file": true,
+	"sign-key": true,
+}
+
+var completionShells = []string{"bash", "zsh", "fish"}
+
+type completionFlag struct {
+	Name		string
+	Usage		string
+	IsBool		bool
+	IsFile		bool
+	Values		[]string
+}
+
+func completionFlags() (flags []completionFlag) {
+	flag.VisitAll(func(f *flag.Flag) {
+		boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+
+		flags = append(flags, completionFlag{
+			Name: f.Name,
+			Usage: f.Usage,
+			IsBool: ok && boolFlag.IsBoolFlag(),
+			IsFile: fileFlags[f.Name],
+			Values: flagValues[f.Name],
+		})
+	})
+
+	return
+}
+
+func completionScript(shell string) (script string, err error) {
+	switch shell {
+	case "bash":
+		return bashCompletion(), nil
+	case "zsh":
+		return zshCompletion(), nil
+	case "fish":
+		return fishCompletion(), nil
+	}
+
+	return "", &configError{fmt.Sprintf("Unknown shell '%s', use one of %s.", shell, strings.Join(completionShells, ", "))}
+}
+
+func bashCompletion() string {
+	var b bytes.Buffer
+	var words []string
+
+	function := "_" + strings.Replace(binaryName, "-", "_", -1)
+
+	fmt.Fprintf(&b, "# bash completion for %s\n", binaryName)
+	fmt.Fprintf(&b, "%s() {\n", function)
+	fmt.Fprintf(&b, "    local cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	fmt.Fprintf(&b, "    local prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n\n")
+	fmt.Fprintf(&b, "    case \"$prev\" in\n")
+	fmt.Fprintf(&b, "    completion)\n        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n        return\n        ;;\n", strings.Join(completionShells, " "))
+
+	for _, f := range completionFlags() {
+		words = append(words, "--"+f.Name)
+
+		switch {
+		case f.IsBool:
+		case len(f.Values) > 0:
+			fmt.Fprintf(&b, "    --%s)\n        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n        return\n        ;;\n", f.Name, strings.Join(f.Values, " "))
+		case f.IsFile:
+			fmt.Fprintf(&b, "    --%s)\n        COMPREPLY=($(compgen -f -- \"$cur\"))\n        return\n        ;;\n", f.Name)
+		default:
+			fmt.Fprintf(&b, "    --%s)\n        COMPREPLY=()\n        return\n        ;;\n", f.Name)
+		}
+	}
+
+	words = append(words, commands...)
+	sort.Strings(words)
+
+	fmt.Fprintf(&b, "    esac\n\n")
+	fmt.Fprintf(&b, "    COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(words, " "))
+	fmt.Fprintf(&b, "}\n\n")
+	fmt.Fprintf(&b, "complete -F %s %s\n", function, binaryName)
+
+	return b.String()
+}
+
+func zshCompletion() string {
+	var b bytes.Buffer
+
+	escape := strings.NewReplacer("'", "'\\''", "[", "\\[", "]", "\\]", ":", "\\:")
+
+	fmt.Fprintf(&b, "#compdef %s\n\n", binaryName)
+	fmt.Fprintf(&b, "_arguments \\\n")
+
+	for _, f := range completionFlags() {
+		switch {
+		case f.IsBool:
+			fmt.Fprintf(&b, "    '--%s[%s]' \\\n", f.Name, escape.Replace(f.Usage))
+		case len(f.Values) > 0:
+			fmt.Fprintf(&b, "    '--%s=[%s]:%s:(%s)' \\\n", f.Name, escape.Replace(f.Usage), f.Name, strings.Join(f.Values, " "))
+		case f.IsFile:
+			fmt.Fprintf(&b, "    '--%s=[%s]:%s:_files' \\\n", f.Name, escape.Replace(f.Usage), f.Name)
+		default:
+			fmt.Fprintf(&b, "    '--%s=[%s]:%s: ' \\\n", f.Name, escape.Replace(f.Usage), f.Name)
+		}
+	}
+
+	fmt.Fprintf(&b, "    '1:command:(%s)' \\\n", strings.Join(commands, " "))
+	fmt.Fprintf(&b, "    '*::argument:->arguments'\n\n")
+	fmt.Fprintf(&b, "case $state in\n")
+	fmt.Fprintf(&b, "arguments)\n")
+	fmt.Fprintf(&b, "    case $words[1] in\n")
+	fmt.Fprintf(&b, "    completion)\n        _values 'shell' %s\n        ;;\n", strings.Join(completionShells, " "))
+	fmt.Fprintf(&b, "    merge|decrypt|verify)\n        _files\n        ;;\n")
+	fmt.Fprintf(&b, "    esac\n")
+	fmt.Fprintf(&b, "    ;;\n")
+	fmt.Fprintf(&b, "esac\n")
+
+	return b.String()
+}
+
+func fishCompletion() string {
+	var b bytes.Buffer
+
+	escape := strings.NewReplacer("\\", "\\\\", "'", "\\'")
+
+	fmt.Fprintf(&b, "# fish completion for %s\n", binaryName)
+	fmt.Fprintf(&b, "complete -c %s -f\n", binaryName)
+	fmt.Fprintf(&b, "complete -c %s -n '__fish_use_subcommand' -a '%s'\n", binaryName, strings.Join(commands, " "))
+	fmt.Fprintf(&b, "complete -c %s -n '__fish_seen_subcommand_from completion' -a '%s'\n", binaryName, strings.Join(completionShells, " "))
+	fmt.Fprintf(&b, "complete -c %s -n '__fish_seen_subcommand_from merge decrypt verify' -F\n", binaryName)
+
+	for _, f := range completionFlags() {
+		switch {
+		case f.IsBool:
+			fmt.Fprintf(&b, "complete -c %s -l %s -d '%s'\n", binaryName, f.Name, escape.Replace(f.Usage))
+		case len(f.Values) > 0:
+			fmt.Fprintf(&b, "complete -c %s -l %s -x -a '%s' -d '%s'\n", binaryName, f.Name, strings.Join(f.Values, " "), escape.Replace(f.Usage))
+		case f.IsFile:
+			fmt.Fprintf(&b, "complete -c %s -l %s -r -F -d '%s'\n", binaryName, f.Name, escape.Replace(f.Usage))
+		default:
+			fmt.Fprintf(&b, "complete -c %s -l %s -x -d '%s'\n", binaryName, f.Name, escape.Replace(f.Usage))
+		}
+	}
+
+	return b.String()
+}
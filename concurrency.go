@@ -0,0 +1,23 @@
+package main
+
+import (
+	"sync"
+)
+
+// parallel runs work on the given number of goroutines and returns once all
+// of them are done, work usually ranges over a channel shared by all of them
+func parallel(workers int, work func()) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			work()
+		}()
+	}
+
+	wg.Wait()
+}
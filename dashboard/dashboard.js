@@ -0,0 +1,134 @@
+// Renders the latest report and the history of the server, see serve.go
+(function () {
+  var sortKey = "project";
+  var projects = [];
+
+  function duration(seconds) {
+    if (seconds === undefined || seconds === null) {
+      return "-";
+    }
+
+    var units = [["d", 86400], ["h", 3600], ["m", 60], ["s", 1]];
+    var parts = [];
+
+    seconds = Math.round(seconds);
+
+    units.forEach(function (unit) {
+      if (seconds >= unit[1] && parts.length < 2) {
+        parts.push(Math.floor(seconds / unit[1]) + unit[0]);
+        seconds %= unit[1];
+      }
+    });
+
+    return parts.length ? parts.join(" ") : "0s";
+  }
+
+  function escape(text) {
+    var div = document.createElement("div");
+    div.textContent = text;
+    return div.innerHTML;
+  }
+
+  function percent(share) {
+    return share === undefined || share === null ? "-" : (share * 100).toFixed(1) + "%";
+  }
+
+  function card(label, value) {
+    return '<div class="card"><div class="value">' + value + '</div><div class="label">' + label + "</div></div>";
+  }
+
+  function renderCurrent(report) {
+    document.getElementById("generated").textContent = "Generated at " + report.metadata.generated_at;
+    document.getElementById("current").innerHTML = [
+      card("MTTR", duration(report.mttr)),
+      card("MTBF", duration(report.mtbf)),
+      card("MTTD", duration(report.mttd)),
+      card("Reopened", percent(report.reopen_rate)),
+      card("Within target", percent(report.compliance)),
+      card("Issues", report.issues),
+      card("Events", report.events)
+    ].join("");
+  }
+
+  function renderProjects() {
+    var sorted = projects.slice().sort(function (a, b) {
+      if (sortKey === "project") {
+        return (a.organization + "/" + a.project).localeCompare(b.organization + "/" + b.project);
+      }
+
+      return (b[sortKey] || 0) - (a[sortKey] || 0);
+    });
+
+    document.querySelector("#projects tbody").innerHTML = sorted.map(function (p) {
+      return '<tr class="' + (p.breaches ? "breached" : "") + '">' +
+        "<td>" + escape(p.organization + "/" + p.project) + "</td>" +
+        "<td>" + p.issues + "</td>" +
+        "<td>" + p.events + "</td>" +
+        "<td>" + duration(p.mttr) + "</td>" +
+        "<td>" + duration(p.mtbf) + "</td>" +
+        "<td>" + percent(p.reopen_rate) + "</td>" +
+        "<td>" + percent(p.compliance) + "</td>" +
+        "</tr>";
+    }).join("");
+  }
+
+  function renderChart(id, series, key) {
+    var values = series.map(function (point) { return point[key] || 0; });
+    var max = Math.max.apply(null, values.concat([1]));
+    var step = values.length > 1 ? 600 / (values.length - 1) : 0;
+    var points = values.map(function (value, i) {
+      return (i * step) + "," + (155 - value / max * 150);
+    });
+
+    document.getElementById(id).innerHTML = '<polyline points="' + points.join(" ") + '"></polyline>';
+  }
+
+  function loadTrend() {
+    var project = document.getElementById("project").value;
+
+    fetch("metrics?project=" + encodeURIComponent(project))
+      .then(function (response) { return response.json(); })
+      .then(function (body) {
+        renderChart("mttr-chart", body.series, "mttr");
+        renderChart("mtbf-chart", body.series, "mtbf");
+      });
+  }
+
+  fetch("report")
+    .then(function (response) {
+      if (response.status === 404) {
+        document.getElementById("empty").hidden = false;
+        return null;
+      }
+
+      return response.json();
+    })
+    .then(function (report) {
+      if (!report) {
+        return;
+      }
+
+      projects = report.per_project || [];
+      renderCurrent(report);
+      renderProjects();
+
+      var select = document.getElementById("project");
+
+      projects.forEach(function (p) {
+        var option = document.createElement("option");
+        option.value = p.organization + "/" + p.project;
+        option.textContent = p.name;
+        select.appendChild(option);
+      });
+
+      select.addEventListener("change", loadTrend);
+      loadTrend();
+    });
+
+  document.querySelectorAll("#projects th").forEach(function (th) {
+    th.addEventListener("click", function () {
+      sortKey = th.dataset.key;
+      renderProjects();
+    });
+  });
+})();
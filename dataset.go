@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"sync"
+)
+
+// datasetRecord is a line of a dataset dump, the events of an issue come
+// before the issue itself and only reference its id, project and owner
+type datasetRecord struct {
+	Kind		string		`json:"kind"`
+	Project		*Project	`json:"project,omitempty"`
+	Issue		*Issue		`json:"issue,omitempty"`
+	Event		*Event		`json:"event,omitempty"`
+}
+
+const (
+	recordProject	= "project"
+	recordIssue	= "issue"
+	recordEvent	= "event"
+)
+
+// dataset writes everything a run crawls as JSON lines, so the datasets of
+// several shards can be merged into one report later. When sealed the lines
+// are encrypted as they're written
+type dataset struct {
+	file		*os.File
+	sealed		io.WriteCloser
+	writer		*bufio.Writer
+	encoder		*json.Encoder
+	err		error
+	mutex		sync.Mutex
+}
+
+func createDataset(path string, seal func(w io.Writer) (io.WriteCloser, error)) (*dataset, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &dataset{file: file}
+	var out io.Writer = file
+
+	if seal != nil {
+		d.sealed, err = seal(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		out = d.sealed
+	}
+
+	d.writer = bufio.NewWriter(out)
+	d.encoder = json.NewEncoder(d.writer)
+
+	return d, nil
+}
+
+// dumpPath is where --dump writes, next to it with the encrypted suffix when
+// --encrypt is set
+func (c *Calculator) dumpPath() string {
+	if c.Options.Dump != "" && c.Options.Encrypt {
+		return c.Options.Dump + encryptedSuffix
+	}
+
+	return c.Options.Dump
+}
+
+func (d *dataset) write(record datasetRecord) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.err == nil {
+		d.err = d.encoder.Encode(record)
+	}
+}
+
+func (d *dataset) close() error {
+	err := d.writer.Flush()
+	if d.err == nil {
+		d.err = err
+	}
+
+	// Sealing the last chunk
+	if d.sealed != nil {
+		err = d.sealed.Close()
+		if d.err == nil {
+			d.err = err
+		}
+	}
+
+	err = d.file.Close()
+	if d.err == nil {
+		d.err = err
+	}
+
+	return d.err
+}
+
+func (c *Calculator) recordProjects(projects []Project, stats *Stats) {
+	stats.addProjects(projects)
+
+	if c.dataset == nil {
+		return
+	}
+
+	for i := range projects {
+		c.dataset.write(datasetRecord{Kind: recordProject, Project: &projects[i]})
+	}
+}
+
+func (c *Calculator) recordEvent(issue Issue, event Event, stats *Stats) {
+	c.calcMTBF(issue, event, stats)
+
+	if c.dataset != nil {
+		c.dataset.write(datasetRecord{Kind: recordEvent, Issue: &Issue{Id: issue.Id, Project: issue.Project, Owner: issue.Owner}, Event: &event})
+	}
+}
+
+func (c *Calculator) recordIssue(issue Issue, stats *Stats) {
+	if c.dataset != nil {
+		c.dataset.write(datasetRecord{Kind: recordIssue, Issue: &issue})
+	}
+
+	if issue.TimeToDetect != nil && !c.checkDuration(issue, durationDetection, *issue.TimeToDetect, stats) {
+		issue.TimeToDetect = nil
+	}
+
+	stats.addIssue(issue)
+	c.calcBurnDown(issue, stats)
+
+	if !c.Options.SkipMTTR {
+		c.calcReopens(issue, stats)
+		c.calcMTTR(issue, stats)
+	}
+}
+
+// replay feeds the datasets dumped by previous runs into stats
+func (c *Calculator) replay(paths []string, stats *Stats) error {
+	for _, path := range paths {
+		c.Log.Info(fmt.Sprintf("Reading dataset '%v'", path))
+
+		err := c.replayFile(path, stats)
+		if err != nil {
+			return fmt.Errorf("Invalid dataset '%v': %v", path, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *Calculator) replayFile(path string, stats *Stats) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var content io.Reader = reader
+
+	// Encrypted datasets are opened chunk by chunk as they're read
+	magic, _ := reader.Peek(len(streamMagic))
+	if string(magic) == streamMagic {
+		content, err = c.decryptStream(reader)
+		if err != nil {
+			return err
+		}
+	}
+
+	decoder := json.NewDecoder(content)
+
+	for {
+		var record datasetRecord
+
+		err = decoder.Decode(&record)
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case record.Kind == recordProject && record.Project != nil:
+			c.recordProjects([]Project{*record.Project}, stats)
+		case record.Kind == recordEvent && record.Issue != nil && record.Event != nil:
+			if !c.Options.SkipMTBF {
+				c.recordEvent(*record.Issue, *record.Event, stats)
+			}
+		case record.Kind == recordIssue && record.Issue != nil:
+			c.recordIssue(*record.Issue, stats)
+		default:
+			return fmt.Errorf("unknown record '%v'", record.Kind)
+		}
+	}
+}
+
+// inShard tells whether a project belongs to the shard of this run, projects
+// are spread by a hash of their organization and slug so every instance
+// agrees on the partition
+func (c *Calculator) inShard(project Project) bool {
+	if c.Options.ShardCount == 0 {
+		return true
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(project.Organization.Slug + "/" + project.Slug))
+
+	return int(hash.Sum32()%uint32(c.Options.ShardCount)) == c.Options.ShardIndex-1
+}
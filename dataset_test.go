@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestInShard(t *testing.T) {
+	var projects []Project
+	for i := 0; i < 200; i++ {
+		projects = append(projects, Project{Slug: fmt.Sprintf("project-%d", i), Organization: Organization{Slug: "acme"}})
+	}
+
+	if !(&Calculator{Options: &Options{}}).inShard(projects[0]) {
+		t.Errorf("without shards every project must be crawled")
+	}
+
+	const count = 5
+	shards := make(map[string]int)
+
+	for index := 1; index <= count; index++ {
+		calculator := &Calculator{Options: &Options{ShardIndex: index, ShardCount: count}}
+		crawled := 0
+
+		for _, project := range projects {
+			if calculator.inShard(project) {
+				shards[project.Slug]++
+				crawled++
+			}
+		}
+
+		if crawled == 0 {
+			t.Errorf("shard %d/%d has no projects", index, count)
+		}
+	}
+
+	// Every instance agrees, each project lands in exactly one shard
+	for _, project := range projects {
+		if shards[project.Slug] != 1 {
+			t.Errorf("%s is in %d shards", project.Slug, shards[project.Slug])
+		}
+	}
+
+	// The organization is part of the hash
+	calculator := &Calculator{Options: &Options{ShardIndex: 1, ShardCount: count}}
+	moved := 0
+
+	for _, project := range projects {
+		other := project
+		other.Organization.Slug = "other"
+
+		if calculator.inShard(project) != calculator.inShard(other) {
+			moved++
+		}
+	}
+
+	if moved == 0 {
+		t.Errorf("projects of another organization land in the same shards")
+	}
+}
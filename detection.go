@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Release is the release an issue was first seen in
+type Release struct {
+	Version		string `json:"version"`
+}
+
+// Deploy is a release going out to an environment
+type Deploy struct {
+	Environment	string `json:"environment"`
+	DateStarted	string `json:"dateStarted"`
+	DateFinished	string `json:"dateFinished"`
+}
+
+func (c *Calculator) requestDeploys(organization Organization, version string, cursor string) (resp *http.Response, err error) {
+	uri := fmt.Sprintf("%s0/organizations/%s/releases/%s/deploys/?cursor=%s", sentryURL, organization.Slug, url.PathEscape(version), cursor)
+
+	return c.request(timingDetails, uri)
+}
+
+func (c *Calculator) getDeploys(organization Organization, version string, cursor string) (deploys []Deploy, err error) {
+	resp, err := c.requestDeploys(organization, version, cursor)
+	if err != nil {
+		return
+	}
+
+	nextCursor, more, err := c.readPage(resp, &deploys)
+	if err != nil || !more {
+		return
+	}
+
+	nextDeploys, err := c.getDeploys(organization, version, nextCursor)
+	deploys = append(deploys, nextDeploys...)
+
+	return
+}
+
+// releaseDeploys returns the deploys of a release, many issues share a
+// release so they are only fetched once per run
+func (c *Calculator) releaseDeploys(organization Organization, version string) (deploys []Deploy, err error) {
+	key := organization.Slug + "/" + version
+
+	c.mutex.Lock()
+	deploys, ok := c.deploys[key]
+	c.mutex.Unlock()
+
+	if ok {
+		return
+	}
+
+	deploys, err = c.getDeploys(organization, version, "0:0:0")
+	if err != nil {
+		return
+	}
+
+	c.mutex.Lock()
+	if c.deploys == nil {
+		c.deploys = make(map[string][]Deploy)
+	}
+	c.deploys[key] = deploys
+	c.mutex.Unlock()
+
+	return
+}
+
+// releasedAt returns when the deploy that shipped the first release of an
+// issue finished, the latest deploy finished before the issue is the one that
+// brought it in, zero when there's none
+func (c *Calculator) releasedAt(issue Issue) (deployed time.Time, err error) {
+	if issue.FirstRelease == nil || issue.FirstRelease.Version == "" {
+		return
+	}
+
+	firstSeen, err := time.Parse(timeFormat, issue.FirstSeen)
+	if err != nil {
+		c.Log.Warn(fmt.Sprintf("Issue #%v has an invalid first seen date: %v", issue.Id, err))
+		return deployed, nil
+	}
+
+	deploys, err := c.releaseDeploys(issue.Project.Organization, issue.FirstRelease.Version)
+	if err != nil {
+		return
+	}
+
+	for _, deploy := range deploys {
+		date := deploy.DateFinished
+		if date == "" {
+			date = deploy.DateStarted
+		}
+
+		deployTime, err := time.Parse(timeFormat, date)
+		if err != nil || deployTime.After(firstSeen) {
+			continue
+		}
+
+		if deployTime.After(deployed) {
+			deployed = deployTime
+		}
+	}
+
+	if deployed.IsZero() {
+		c.Log.Debug(fmt.Sprintf("Issue #%v has no deploy of release %s before it", issue.Id, issue.FirstRelease.Version))
+	}
+
+	return
+}
+
+// timeToDetect is the time from the release of an issue to the issue being
+// first seen
+func (c *Calculator) timeToDetect(issue Issue, deployed time.Time) *float64 {
+	firstSeen, err := time.Parse(timeFormat, issue.FirstSeen)
+	if err != nil || deployed.IsZero() {
+		c.Log.Debug(fmt.Sprintf("Issue #%v dropped from MTTD, its release isn't known", issue.Id))
+		return nil
+	}
+
+	seconds := firstSeen.Sub(deployed).Seconds()
+	c.Log.Debug(fmt.Sprintf("Issue #%v took %.0f seconds to be detected", issue.Id, seconds))
+
+	return &seconds
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/tealeg/xlsx"
+)
+
+// Durations checked against --min-duration and --max-duration
+const (
+	durationRepair		= "time_to_repair"
+	durationAssigned	= "assigned_to_resolved"
+	durationDetection	= "time_to_detect"
+)
+
+const (
+	reasonBelowMinimum	= "below_minimum"
+	reasonAboveMaximum	= "above_maximum"
+)
+
+// What happens to the durations out of bounds
+const (
+	boundsFlag	= "flag"
+	boundsExclude	= "exclude"
+)
+
+// Diagnostic is a duration out of bounds, negative ones come from clock skew
+// and multi-year ones usually from imported data
+type Diagnostic struct {
+	Issue		string	`json:"issue"`
+	Project		string	`json:"project"`
+	Kind		string	`json:"kind"`
+	Duration	float64	`json:"duration"`
+	Reason		string	`json:"reason"`
+	Excluded	bool	`json:"excluded"`
+}
+
+// checkDuration tells whether a duration of an issue is kept in the metrics,
+// the ones out of bounds are recorded as diagnostics
+func (c *Calculator) checkDuration(issue Issue, kind string, seconds float64, stats *Stats) bool {
+	reason := ""
+
+	switch {
+	case seconds < c.Options.MinDuration.Seconds():
+		reason = reasonBelowMinimum
+	case c.Options.MaxDuration > 0 && seconds > c.Options.MaxDuration.Seconds():
+		reason = reasonAboveMaximum
+	default:
+		return true
+	}
+
+	excluded := c.Options.OutOfBounds == boundsExclude
+
+	c.Log.Warn(fmt.Sprintf("Issue #%v has an implausible %s of %.0f seconds", issue.Id, kind, seconds))
+
+	stats.addDiagnostic(Diagnostic{
+		Issue: issue.Id,
+		Project: issue.Project.Organization.Slug + "/" + issue.Project.Slug,
+		Kind: kind,
+		Duration: seconds,
+		Reason: reason,
+		Excluded: excluded,
+	})
+
+	return !excluded
+}
+
+// diagnostics returns the diagnostics sorted by project, issue and kind
+func (s *Stats) diagnostics() []Diagnostic {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	diagnostics := append([]Diagnostic(nil), s.outOfBounds...)
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].Project != diagnostics[j].Project {
+			return diagnostics[i].Project < diagnostics[j].Project
+		}
+
+		if diagnostics[i].Issue != diagnostics[j].Issue {
+			return diagnostics[i].Issue < diagnostics[j].Issue
+		}
+
+		return diagnostics[i].Kind < diagnostics[j].Kind
+	})
+
+	return diagnostics
+}
+
+func (s *Stats) addDiagnostic(diagnostic Diagnostic) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.outOfBounds = append(s.outOfBounds, diagnostic)
+}
+
+func (c *Calculator) saveDiagnosticsIntoXLSX(diagnostics []Diagnostic) {
+	var file *xlsx.File
+	var sheet *xlsx.Sheet
+	var row *xlsx.Row
+	var cell *xlsx.Cell
+	var err error
+
+	outputFile := fmt.Sprintf("diagnostics_%v", sheetName)
+
+	c.Log.Info(fmt.Sprintf("Output file '%v'", outputFile))
+
+	file = xlsx.NewFile()
+	sheet, err = file.AddSheet(c.text("sheet.diagnostics"))
+	if err != nil {
+		panic(err.Error())
+	}
+
+	row = sheet.AddRow()
+	cell = row.AddCell()
+	cell.Value = c.text("heading.issue_id")
+	cell = row.AddCell()
+	cell.Value = c.text("heading.project_name")
+	cell = row.AddCell()
+	cell.Value = c.text("heading.kind")
+	cell = row.AddCell()
+	cell.Value = c.text("heading.duration")
+	cell = row.AddCell()
+	cell.Value = c.text("heading.reason")
+	cell = row.AddCell()
+	cell.Value = c.text("heading.excluded")
+
+	for _, diagnostic := range diagnostics {
+		row = sheet.AddRow()
+		cell = row.AddCell()
+		cell.Value = diagnostic.Issue
+		cell = row.AddCell()
+		cell.Value = diagnostic.Project
+		cell = row.AddCell()
+		cell.Value = c.text("duration." + diagnostic.Kind)
+		cell = row.AddCell()
+		setSecondsCell(cell, diagnostic.Duration)
+		cell = row.AddCell()
+		cell.Value = c.text("reason." + diagnostic.Reason)
+		cell = row.AddCell()
+		cell.Value = c.text(fmt.Sprintf("excluded.%v", diagnostic.Excluded))
+	}
+
+	c.saveXLSX(file, outputFile)
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/tealeg/xlsx"
+)
+
+// Encrypted files start with a magic and the salt and nonce of AES-256-GCM,
+// the key derives from the passphrase with PBKDF2-SHA256 or is the key file
+const (
+	encryptionMagic		= "SMMCENC1"
+	encryptedSuffix		= ".enc"
+	saltSize		= 16
+	keySize			= 32
+	pbkdf2Iterations	= 600000
+)
+
+// Datasets are too large to seal in one piece, they're sealed in chunks of
+// streamChunkSize, each with the chunk counter in its nonce and the last one
+// marked in its additional data so a truncated file fails to decrypt
+const (
+	streamMagic		= "SMMCSTR1"
+	streamChunkSize		= 64 * 1024
+	streamCounterSize	= 8
+)
+
+// encryptionKey returns the key of the passphrase in OUTPUT_PASSPHRASE or of
+// --key-file, which holds 32 raw bytes or 64 hex characters
+func (c *Calculator) encryptionKey(salt []byte) (key []byte, err error) {
+	if c.Options.KeyFile != "" {
+		content, err := ioutil.ReadFile(c.Options.KeyFile)
+		if err != nil {
+			return nil, &configError{fmt.Sprintf("Can't read key file '%v': %v", c.Options.KeyFile, err)}
+		}
+
+		if len(content) == keySize {
+			return content, nil
+		}
+
+		key, err = hex.DecodeString(strings.TrimSpace(string(content)))
+		if err != nil || len(key) != keySize {
+			return nil, &configError{fmt.Sprintf("Key file '%v' must hold %d bytes, raw or in hex.", c.Options.KeyFile, keySize)}
+		}
+
+		return key, nil
+	}
+
+	passphrase := os.Getenv("OUTPUT_PASSPHRASE")
+	if passphrase == "" {
+		return nil, &configError{"Encryption needs OUTPUT_PASSPHRASE or --key-file."}
+	}
+
+	return pbkdf2SHA256([]byte(passphrase), salt, pbkdf2Iterations, keySize), nil
+}
+
+func (c *Calculator) encrypt(plaintext []byte) (ciphertext []byte, err error) {
+	salt := make([]byte, saltSize)
+	_, err = rand.Read(salt)
+	if err != nil {
+		return
+	}
+
+	key, err := c.encryptionKey(salt)
+	if err != nil {
+		return
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return
+	}
+
+	header := append(append([]byte(encryptionMagic), salt...), nonce...)
+
+	// The header is authenticated too, tampering with the salt fails to decrypt
+	return aead.Seal(header, nonce, plaintext, header), nil
+}
+
+func (c *Calculator) decrypt(ciphertext []byte) (plaintext []byte, err error) {
+	if !bytes.HasPrefix(ciphertext, []byte(encryptionMagic)) {
+		return nil, fmt.Errorf("Not an encrypted report.")
+	}
+
+	salt := ciphertext[len(encryptionMagic):]
+	if len(salt) < saltSize {
+		return nil, fmt.Errorf("Encrypted report is truncated.")
+	}
+
+	salt = salt[:saltSize]
+
+	key, err := c.encryptionKey(salt)
+	if err != nil {
+		return
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return
+	}
+
+	headerSize := len(encryptionMagic) + saltSize + aead.NonceSize()
+	if len(ciphertext) < headerSize {
+		return nil, fmt.Errorf("Encrypted report is truncated.")
+	}
+
+	header := ciphertext[:headerSize]
+
+	plaintext, err = aead.Open(nil, header[len(encryptionMagic)+saltSize:], ciphertext[headerSize:], header)
+	if err != nil {
+		return nil, fmt.Errorf("Can't decrypt, wrong passphrase or key, or the file was modified.")
+	}
+
+	return
+}
+
+// streamWriter seals what's written to it chunk by chunk into w, Close seals
+// the last chunk
+type streamWriter struct {
+	w		io.Writer
+	aead		cipher.AEAD
+	header		[]byte
+	nonce		[]byte
+	counter		uint64
+	buffer		[]byte
+}
+
+// encryptStream writes the header of a sealed stream to w and returns the
+// writer sealing into it
+func (c *Calculator) encryptStream(w io.Writer) (io.WriteCloser, error) {
+	salt := make([]byte, saltSize)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := c.encryptionKey(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	header := append(append([]byte(streamMagic), salt...), nonce...)
+
+	_, err = w.Write(header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &streamWriter{w: w, aead: aead, header: header, nonce: nonce}, nil
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	s.buffer = append(s.buffer, p...)
+
+	// A full chunk is only sealed once more follows, the last one is sealed
+	// on close
+	for len(s.buffer) > streamChunkSize {
+		err := s.seal(s.buffer[:streamChunkSize], false)
+		if err != nil {
+			return 0, err
+		}
+
+		s.buffer = append(s.buffer[:0], s.buffer[streamChunkSize:]...)
+	}
+
+	return len(p), nil
+}
+
+func (s *streamWriter) Close() error {
+	return s.seal(s.buffer, true)
+}
+
+func (s *streamWriter) seal(chunk []byte, last bool) error {
+	nonce := chunkNonce(s.nonce, s.counter)
+	s.counter++
+
+	_, err := s.w.Write(s.aead.Seal(nil, nonce, chunk, chunkData(s.header, last)))
+
+	return err
+}
+
+// streamReader opens a sealed stream chunk by chunk
+type streamReader struct {
+	r		*bufio.Reader
+	aead		cipher.AEAD
+	header		[]byte
+	nonce		[]byte
+	counter		uint64
+	chunk		[]byte
+	plaintext	[]byte
+	done		bool
+}
+
+// decryptStream reads the header of a sealed stream and returns the reader of
+// its plaintext
+func (c *Calculator) decryptStream(r io.Reader) (io.Reader, error) {
+	reader := bufio.NewReader(r)
+
+	header := make([]byte, len(streamMagic)+saltSize)
+	_, err := io.ReadFull(reader, header)
+	if err != nil || string(header[:len(streamMagic)]) != streamMagic {
+		return nil, fmt.Errorf("Not an encrypted dataset.")
+	}
+
+	key, err := c.encryptionKey(header[len(streamMagic):])
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = io.ReadFull(reader, nonce)
+	if err != nil {
+		return nil, fmt.Errorf("Encrypted dataset is truncated.")
+	}
+
+	return &streamReader{
+		r: reader,
+		aead: aead,
+		header: append(header, nonce...),
+		nonce: nonce,
+		chunk: make([]byte, streamChunkSize+aead.Overhead()),
+	}, nil
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	for len(s.plaintext) == 0 {
+		if s.done {
+			return 0, io.EOF
+		}
+
+		err := s.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, s.plaintext)
+	s.plaintext = s.plaintext[n:]
+
+	return n, nil
+}
+
+func (s *streamReader) open() error {
+	n, err := io.ReadFull(s.r, s.chunk)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+
+	// The last chunk is the one nothing follows
+	last := err != nil
+	if !last {
+		_, peekErr := s.r.Peek(1)
+		last = peekErr == io.EOF
+	}
+
+	nonce := chunkNonce(s.nonce, s.counter)
+	s.counter++
+
+	plaintext, err := s.aead.Open(nil, nonce, s.chunk[:n], chunkData(s.header, last))
+	if err != nil {
+		return fmt.Errorf("Can't decrypt, wrong passphrase or key, or the file was modified or truncated.")
+	}
+
+	s.plaintext, s.done = plaintext, last
+
+	return nil
+}
+
+// chunkNonce xors the counter of a chunk into the end of the stream nonce
+func chunkNonce(nonce []byte, counter uint64) []byte {
+	chunk := append([]byte(nil), nonce...)
+
+	var encoded [streamCounterSize]byte
+	binary.BigEndian.PutUint64(encoded[:], counter)
+
+	for i := range encoded {
+		chunk[len(chunk)-streamCounterSize+i] ^= encoded[i]
+	}
+
+	return chunk
+}
+
+// chunkData authenticates the header along with whether a chunk is the last
+func chunkData(header []byte, last bool) []byte {
+	data := append([]byte(nil), header...)
+
+	if last {
+		return append(data, 1)
+	}
+
+	return append(data, 0)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2SHA256 derives a key from a passphrase as in RFC 8018
+func pbkdf2SHA256(passphrase []byte, salt []byte, iterations int, size int) []byte {
+	prf := hmac.New(sha256.New, passphrase)
+	var key []byte
+
+	for block := uint32(1); len(key) < size; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.Write(prf, binary.BigEndian, block)
+
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+
+		key = append(key, t...)
+	}
+
+	return key[:size]
+}
+
+// saveXLSX writes a spreadsheet, encrypted next to where it would be when
+// --encrypt is set
+func (c *Calculator) saveXLSX(file *xlsx.File, outputFile string) {
+	if !c.Options.Encrypt {
+		err := file.Save(outputFile)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		c.addFileArtifact(outputFile)
+		return
+	}
+
+	var buffer bytes.Buffer
+
+	err := file.Write(&buffer)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	c.writeEncrypted(outputFile+encryptedSuffix, buffer.Bytes())
+}
+
+func (c *Calculator) writeEncrypted(outputFile string, plaintext []byte) {
+	ciphertext, err := c.encrypt(plaintext)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	err = ioutil.WriteFile(outputFile, ciphertext, 0600)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	c.addArtifact(outputFile, ciphertext)
+}
+
+// Decrypt writes the plaintext of an encrypted report or dataset to output,
+// or to stdout when output is empty, and returns the process exit code
+func (c *Calculator) Decrypt(input string, output string) int {
+	file, err := os.Open(input)
+	if err != nil {
+		c.Log.Error(err.Error())
+		return exitFailure
+	}
+
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var plaintext io.Reader
+
+	// Datasets are opened as a stream, reports are sealed in one piece
+	magic, _ := reader.Peek(len(streamMagic))
+	if string(magic) == streamMagic {
+		plaintext, err = c.decryptStream(reader)
+	} else {
+		var ciphertext, content []byte
+
+		ciphertext, err = ioutil.ReadAll(reader)
+		if err == nil {
+			content, err = c.decrypt(ciphertext)
+			plaintext = bytes.NewReader(content)
+		}
+	}
+
+	if _, ok := err.(*configError); ok {
+		c.Log.Error(err.Error())
+		return exitConfig
+	}
+
+	if err != nil {
+		c.Log.Error(err.Error())
+		return exitFailure
+	}
+
+	out := os.Stdout
+
+	if output != "" {
+		out, err = os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			c.Log.Error(err.Error())
+			return exitFailure
+		}
+
+		defer out.Close()
+	}
+
+	_, err = io.Copy(out, plaintext)
+	if err != nil {
+		c.Log.Error(err.Error())
+		return exitFailure
+	}
+
+	return exitSuccess
+}
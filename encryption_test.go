@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testEncryption(t *testing.T, key string) *Calculator {
+	keyFile := filepath.Join(t.TempDir(), "key")
+
+	err := ioutil.WriteFile(keyFile, []byte(key), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Calculator{Options: &Options{Encrypt: true, KeyFile: keyFile}}
+}
+
+const (
+	testKey		= "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	otherKey	= "ff0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+)
+
+func TestEncryptRoundTrip(t *testing.T) {
+	c := testEncryption(t, testKey)
+	plaintext := []byte(`{"mttr": 3600}`)
+
+	ciphertext, err := c.encrypt(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(ciphertext, plaintext) {
+		t.Errorf("the ciphertext holds the plaintext")
+	}
+
+	decrypted, err := c.decrypt(ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("got %q, want %q", decrypted, plaintext)
+	}
+
+	_, err = testEncryption(t, otherKey).decrypt(ciphertext)
+	if err == nil {
+		t.Errorf("another key decrypted the report")
+	}
+
+	ciphertext[len(encryptionMagic)] ^= 1
+
+	_, err = c.decrypt(ciphertext)
+	if err == nil {
+		t.Errorf("a modified salt decrypted the report")
+	}
+}
+
+func sealStream(t *testing.T, c *Calculator, plaintext []byte) []byte {
+	var buffer bytes.Buffer
+
+	w, err := c.encryptStream(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Written in odd sizes, as the JSON encoder does
+	for len(plaintext) > 0 {
+		n := 1000
+		if n > len(plaintext) {
+			n = len(plaintext)
+		}
+
+		_, err = w.Write(plaintext[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		plaintext = plaintext[n:]
+	}
+
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buffer.Bytes()
+}
+
+func openStream(c *Calculator, ciphertext []byte) ([]byte, error) {
+	r, err := c.decryptStream(bytes.NewReader(ciphertext))
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(r)
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	c := testEncryption(t, testKey)
+
+	for _, size := range []int{0, 1, streamChunkSize - 1, streamChunkSize, streamChunkSize + 1, 3*streamChunkSize + 17} {
+		plaintext := []byte(strings.Repeat("{\"kind\":\"event\"}\n", size/17+1)[:size])
+
+		decrypted, err := openStream(c, sealStream(t, c, plaintext))
+		if err != nil {
+			t.Fatalf("%d bytes: %v", size, err)
+		}
+
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("%d bytes: got %d bytes back", size, len(decrypted))
+		}
+	}
+}
+
+func TestStreamTampering(t *testing.T) {
+	c := testEncryption(t, testKey)
+	plaintext := bytes.Repeat([]byte("x"), 2*streamChunkSize+10)
+	ciphertext := sealStream(t, c, plaintext)
+	chunk := streamChunkSize + 16
+	header := len(streamMagic) + saltSize + 12
+
+	cases := map[string][]byte{
+		"truncated at a chunk": ciphertext[:header+chunk],
+		"truncated in a chunk": ciphertext[:header+chunk+100],
+		"chunks swapped": append(append(append([]byte(nil), ciphertext[:header]...), ciphertext[header+chunk:header+2*chunk]...), append(append([]byte(nil), ciphertext[header:header+chunk]...), ciphertext[header+2*chunk:]...)...),
+		"flipped bit": func() []byte {
+			modified := append([]byte(nil), ciphertext...)
+			modified[header+chunk+5] ^= 1
+			return modified
+		}(),
+	}
+
+	for name, modified := range cases {
+		_, err := openStream(c, modified)
+		if err == nil {
+			t.Errorf("%s: decrypted", name)
+		}
+	}
+
+	_, err := openStream(testEncryption(t, otherKey), ciphertext)
+	if err == nil {
+		t.Errorf("another key decrypted the stream")
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Rough memory held by an event besides its strings
+const eventOverhead = 64
+
+// eventStore keeps events for a sorted pass over them, once the memory budget
+// is exceeded the buffer is sorted and spilled to disk as a run, and the pass
+// merges all runs
+type eventStore struct {
+	budget		int
+	size		int
+	count		int
+	events		[]Event
+	dir		string
+	runs		[]string
+	err		error
+}
+
+func newEventStore(budget int) *eventStore {
+	return &eventStore{budget: budget}
+}
+
+func (s *eventStore) add(event Event) {
+	if s.err != nil {
+		return
+	}
+
+	s.events = append(s.events, event)
+	s.size += len(event.Id) + len(event.DateCreated) + len(event.Project) + len(event.Owner) + eventOverhead
+
+	for _, tag := range event.Tags {
+		s.size += len(tag.Key) + len(tag.Value) + eventOverhead
+	}
+	s.count++
+
+	if s.budget > 0 && s.size > s.budget {
+		s.err = s.spill()
+	}
+}
+
+func (s *eventStore) len() int {
+	return s.count
+}
+
+func (s *eventStore) spill() (err error) {
+	if s.dir == "" {
+		s.dir, err = ioutil.TempDir("", "sentry-mttr-mtbf-")
+		if err != nil {
+			return
+		}
+	}
+
+	sortEventsBasedOnTime(s.events)
+
+	path := filepath.Join(s.dir, fmt.Sprintf("run-%d", len(s.runs)))
+
+	file, err := os.Create(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := gob.NewEncoder(writer)
+
+	for _, event := range s.events {
+		err = encoder.Encode(event)
+		if err != nil {
+			return
+		}
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		return
+	}
+
+	s.runs = append(s.runs, path)
+	s.events = nil
+	s.size = 0
+
+	return
+}
+
+// each calls fn for every event sorted by date
+func (s *eventStore) each(fn func(Event)) (err error) {
+	if s.err != nil {
+		return s.err
+	}
+
+	sortEventsBasedOnTime(s.events)
+
+	if len(s.runs) == 0 {
+		for _, event := range s.events {
+			fn(event)
+		}
+
+		return
+	}
+
+	merge := &eventMerge{}
+
+	for _, path := range s.runs {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		err = merge.push(&eventRun{decoder: gob.NewDecoder(bufio.NewReader(file))})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = merge.push(&eventRun{events: s.events})
+	if err != nil {
+		return
+	}
+
+	for merge.Len() > 0 {
+		run := (*merge)[0]
+		fn(run.head)
+
+		err = run.next()
+		if err == io.EOF {
+			heap.Pop(merge)
+			continue
+		}
+
+		if err != nil {
+			return
+		}
+
+		heap.Fix(merge, 0)
+	}
+
+	return nil
+}
+
+// close removes the spilled runs
+func (s *eventStore) close() {
+	if s.dir != "" {
+		os.RemoveAll(s.dir)
+	}
+}
+
+// eventRun is a sorted sequence of events, read from disk or from memory
+type eventRun struct {
+	head		Event
+	decoder		*gob.Decoder
+	events		[]Event
+}
+
+func (r *eventRun) next() error {
+	if r.decoder != nil {
+		r.head = Event{}
+		return r.decoder.Decode(&r.head)
+	}
+
+	if len(r.events) == 0 {
+		return io.EOF
+	}
+
+	r.head = r.events[0]
+	r.events = r.events[1:]
+
+	return nil
+}
+
+// eventMerge is a heap of runs ordered by their head event
+type eventMerge []*eventRun
+
+func (m eventMerge) Len() int { return len(m) }
+func (m eventMerge) Less(i, j int) bool { return m[i].head.DateCreated < m[j].head.DateCreated }
+func (m eventMerge) Swap(i, j int) { m[i], m[j] = m[j], m[i] }
+func (m *eventMerge) Push(x interface{}) { *m = append(*m, x.(*eventRun)) }
+
+func (m *eventMerge) Pop() interface{} {
+	old := *m
+	run := old[len(old)-1]
+	*m = old[:len(old)-1]
+
+	return run
+}
+
+// push adds a run to the merge unless it is empty
+func (m *eventMerge) push(run *eventRun) error {
+	err := run.next()
+	if err == io.EOF {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	heap.Push(m, run)
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+)
+
+// Exit codes of a run, automation relies on these so don't renumber them
+const (
+	exitSuccess	= 0
+	exitFailure	= 1
+	exitPartial	= 2
+	exitThreshold	= 3
+	exitConfig	= 4
+)
+
+// authError is returned when Sentry refuses the configured token
+type authError struct {
+	Method		string
+	URI		string
+	StatusCode	int
+}
+
+func (e *authError) Error() string {
+	method := e.Method
+	if method == "" {
+		method = "GET"
+	}
+
+	return fmt.Sprintf("%s %s was refused with HTTP %d, check the Sentry credentials", method, e.URI, e.StatusCode)
+}
+
+// configError is returned when the run can't start because of its configuration
+type configError struct {
+	Message		string
+}
+
+func (e *configError) Error() string {
+	return e.Message
+}
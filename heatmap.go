@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tealeg/xlsx"
+)
+
+// heatmap counts occurrences by weekday, starting on Sunday, and hour in UTC
+type heatmap [7][24]int
+
+func (h *heatmap) add(date time.Time) {
+	date = date.UTC()
+	h[date.Weekday()][date.Hour()]++
+}
+
+// rows returns the counts as one row of 24 hours per weekday
+func (h *heatmap) rows() (rows [][]int) {
+	for _, hours := range h {
+		rows = append(rows, append([]int(nil), hours[:]...))
+	}
+
+	return
+}
+
+// Heatmap tells when failures happen and when fixes land, one row per weekday
+// from Sunday with one column per hour of the day in UTC
+type Heatmap struct {
+	Events		[][]int	`json:"events,omitempty"`
+	Resolutions	[][]int	`json:"resolutions,omitempty"`
+}
+
+func (c *Calculator) saveHeatmapIntoXLSX(heatmap *Heatmap) {
+	var file *xlsx.File
+
+	outputFile := fmt.Sprintf("heatmap_%v", sheetName)
+
+	c.Log.Info(fmt.Sprintf("Output file '%v'", outputFile))
+
+	file = xlsx.NewFile()
+
+	if heatmap.Events != nil {
+		c.addHeatmapSheet(file, c.text("sheet.events"), heatmap.Events)
+	}
+
+	if heatmap.Resolutions != nil {
+		c.addHeatmapSheet(file, c.text("sheet.resolutions"), heatmap.Resolutions)
+	}
+
+	c.saveXLSX(file, outputFile)
+}
+
+func (c *Calculator) addHeatmapSheet(file *xlsx.File, name string, rows [][]int) {
+	var sheet *xlsx.Sheet
+	var row *xlsx.Row
+	var cell *xlsx.Cell
+	var err error
+
+	sheet, err = file.AddSheet(name)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	row = sheet.AddRow()
+	cell = row.AddCell()
+	cell.Value = c.text("heading.weekday")
+
+	for hour := 0; hour < 24; hour++ {
+		cell = row.AddCell()
+		cell.Value = fmt.Sprintf("%02d:00", hour)
+	}
+
+	for weekday, hours := range rows {
+		row = sheet.AddRow()
+		cell = row.AddCell()
+		cell.Value = c.weekday(time.Weekday(weekday))
+
+		for _, count := range hours {
+			cell = row.AddCell()
+			setCountCell(cell, count)
+		}
+	}
+}
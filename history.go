@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// history is a JSONL file with the report of every run, appended to by the
+// runs and read by the metrics API
+type history struct {
+	path		string
+	mutex		sync.Mutex
+}
+
+// MetricsPoint is the value of the metrics of a run, for all the projects or
+// for one of them
+type MetricsPoint struct {
+	GeneratedAt	string		`json:"generated_at"`
+	MTTR		*float64	`json:"mttr,omitempty"`
+	MTBF		*float64	`json:"mtbf,omitempty"`
+	MTTD		*float64	`json:"mttd,omitempty"`
+	ReopenRate	*float64	`json:"reopen_rate,omitempty"`
+	Compliance	*float64	`json:"compliance,omitempty"`
+	Issues		int		`json:"issues"`
+	Events		int		`json:"events"`
+}
+
+func newHistory(path string) *history {
+	return &history{path: path}
+}
+
+func (h *history) append(report Report) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	// The run isn't compared to its own baseline when read back
+	report.Baseline = nil
+
+	line, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("Can't open history '%v': %v", h.path, err)
+	}
+
+	_, err = file.Write(append(line, '\n'))
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("Can't write history '%v': %v", h.path, err)
+	}
+
+	return file.Close()
+}
+
+// series returns the metrics of the runs generated between from and to, zero
+// bounds are open, for a project given as organization/slug or slug, or for
+// all of them when project is empty
+func (h *history) series(project string, from time.Time, to time.Time) (points []MetricsPoint, err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	points = []MetricsPoint{}
+
+	file, err := os.Open(h.path)
+	if os.IsNotExist(err) {
+		return points, nil
+	}
+
+	if err != nil {
+		return
+	}
+
+	defer file.Close()
+
+	decoder := json.NewDecoder(bufio.NewReader(file))
+
+	for {
+		var report Report
+
+		err = decoder.Decode(&report)
+		if err == io.EOF {
+			return points, nil
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Can't read history '%v': %v", h.path, err)
+		}
+
+		generatedAt, err := time.Parse(timeFormat, report.Metadata.GeneratedAt)
+		if err != nil || (!from.IsZero() && generatedAt.Before(from)) || (!to.IsZero() && generatedAt.After(to)) {
+			continue
+		}
+
+		point, ok := report.point(project)
+		if ok {
+			points = append(points, point)
+		}
+	}
+}
+
+// latest returns the report of the last run, if any
+func (h *history) latest() (report *Report, err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	file, err := os.Open(h.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return
+	}
+
+	defer file.Close()
+
+	decoder := json.NewDecoder(bufio.NewReader(file))
+
+	for {
+		var next Report
+
+		err = decoder.Decode(&next)
+		if err == io.EOF {
+			return report, nil
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Can't read history '%v': %v", h.path, err)
+		}
+
+		report = &next
+	}
+}
+
+// point picks the metrics of a project out of a report
+func (r Report) point(project string) (point MetricsPoint, ok bool) {
+	point.GeneratedAt = r.Metadata.GeneratedAt
+
+	if project == "" {
+		point.MTTR, point.MTBF, point.MTTD = r.MTTR, r.MTBF, r.MTTD
+		point.ReopenRate, point.Compliance = r.ReopenRate, r.Compliance
+		point.Issues, point.Events = r.Issues, r.Events
+
+		return point, true
+	}
+
+	for _, metrics := range r.PerProject {
+		if metrics.Organization+"/"+metrics.Project != project && metrics.Project != project {
+			continue
+		}
+
+		point.MTTR, point.MTBF, point.MTTD = metrics.MTTR, metrics.MTBF, metrics.MTTD
+		point.ReopenRate, point.Compliance = metrics.ReopenRate, metrics.Compliance
+		point.Issues, point.Events = metrics.Issues, metrics.Events
+
+		return point, true
+	}
+
+	return
+}
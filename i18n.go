@@ -0,0 +1,192 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	langEN		= "en"
+	langPTBR	= "pt-BR"
+	langES		= "es"
+)
+
+var languages = []string{langEN, langPTBR, langES}
+
+// catalogs hold the labels of the spreadsheets and the summary per language,
+// missing entries fall back to English
+var catalogs = map[string]map[string]string{
+	langEN: {
+		"sheet.events":			"Events",
+		"sheet.resolutions":		"Resolutions",
+		"sheet.open_issues":		"Open Issues",
+		"heading.issue_id":		"Issue Id",
+		"heading.issue_status":		"Issue Status",
+		"heading.project_name":		"Project Name",
+		"heading.environment":		"Environment",
+		"heading.owner":		"Owner",
+		"heading.time_to_resolve":	"Time to Resolve In Seconds",
+		"heading.assigned_to_resolved":	"Assigned to Resolved In Seconds",
+		"heading.mttr_target":		"MTTR Target In Seconds",
+		"heading.event_id":		"Event Id",
+		"heading.created_at":		"Created At",
+		"heading.duration":		"Duration In Seconds",
+		"heading.weekday":		"Weekday",
+		"heading.date":			"Date",
+		"heading.open_issues":		"Open Issues",
+		"weekday.Sunday":		"Sunday",
+		"weekday.Monday":		"Monday",
+		"weekday.Tuesday":		"Tuesday",
+		"weekday.Wednesday":		"Wednesday",
+		"weekday.Thursday":		"Thursday",
+		"weekday.Friday":		"Friday",
+		"weekday.Saturday":		"Saturday",
+		"summary.title":		"Sentry reliability report, %s",
+		"summary.totals":		"%d projects, %d issues, %d events",
+		"summary.mttr":			"MTTR: %s",
+		"summary.assigned_mttr":	"MTTR after assignment: %s",
+		"summary.reopen_rate":		"Reopen rate: %.1f%%",
+		"summary.compliance":		"MTTR target compliance: %.1f%%",
+		"summary.mttd":			"MTTD: %s",
+		"summary.mtbf":			"MTBF: %s",
+		"summary.group_mttr":		", MTTR %s",
+		"summary.group_mtbf":		", MTBF %s",
+		"summary.group_reopened":	", %.1f%% reopened",
+		"summary.group_compliance":	", %.1f%% within the %s target",
+		"summary.group_breaches":	", %d breached ⚠️",
+		"summary.resolution_source":	"Resolved %s: %.1f%%",
+		"summary.delta":		" (%s vs baseline)",
+		"summary.bursts":		"MTBF collapsed %d events within %s of the previous one",
+		"summary.diagnostics":		"%d durations out of bounds, %d excluded",
+		"sheet.diagnostics":		"Diagnostics",
+		"heading.kind":			"Kind",
+		"heading.reason":		"Reason",
+		"heading.excluded":		"Excluded",
+		"duration.time_to_repair":	"Time to Repair",
+		"duration.assigned_to_resolved":	"Assigned to Resolved",
+		"duration.time_to_detect":	"Time to Detect",
+		"reason.below_minimum":		"Below Minimum",
+		"reason.above_maximum":		"Above Maximum",
+		"excluded.true":		"Yes",
+		"excluded.false":		"No",
+	},
+	langPTBR: {
+		"sheet.events":			"Eventos",
+		"sheet.resolutions":		"Resoluções",
+		"sheet.open_issues":		"Issues Abertas",
+		"heading.issue_id":		"Id da Issue",
+		"heading.issue_status":		"Status da Issue",
+		"heading.project_name":		"Nome do Projeto",
+		"heading.environment":		"Ambiente",
+		"heading.owner":		"Responsável",
+		"heading.time_to_resolve":	"Tempo para Resolver em Segundos",
+		"heading.assigned_to_resolved":	"Da Atribuição à Resolução em Segundos",
+		"heading.mttr_target":		"Meta de MTTR em Segundos",
+		"heading.event_id":		"Id do Evento",
+		"heading.created_at":		"Criado em",
+		"heading.duration":		"Duração em Segundos",
+		"heading.weekday":		"Dia da Semana",
+		"heading.date":			"Data",
+		"heading.open_issues":		"Issues Abertas",
+		"weekday.Sunday":		"Domingo",
+		"weekday.Monday":		"Segunda-feira",
+		"weekday.Tuesday":		"Terça-feira",
+		"weekday.Wednesday":		"Quarta-feira",
+		"weekday.Thursday":		"Quinta-feira",
+		"weekday.Friday":		"Sexta-feira",
+		"weekday.Saturday":		"Sábado",
+		"summary.title":		"Relatório de confiabilidade do Sentry, %s",
+		"summary.totals":		"%d projetos, %d issues, %d eventos",
+		"summary.assigned_mttr":	"MTTR após a atribuição: %s",
+		"summary.reopen_rate":		"Taxa de reabertura: %.1f%%",
+		"summary.compliance":		"Cumprimento da meta de MTTR: %.1f%%",
+		"summary.group_reopened":	", %.1f%% reabertas",
+		"summary.group_compliance":	", %.1f%% dentro da meta de %s",
+		"summary.group_breaches":	", %d violações ⚠️",
+		"summary.resolution_source":	"Resolvidas via %s: %.1f%%",
+		"summary.delta":		" (%s em relação à referência)",
+		"summary.bursts":		"O MTBF agrupou %d eventos a até %s do anterior",
+		"summary.diagnostics":		"%d durações fora dos limites, %d excluídas",
+		"sheet.diagnostics":		"Diagnóstico",
+		"heading.kind":			"Tipo",
+		"heading.reason":		"Motivo",
+		"heading.excluded":		"Excluída",
+		"duration.time_to_repair":	"Tempo para Reparar",
+		"duration.assigned_to_resolved":	"Da Atribuição à Resolução",
+		"duration.time_to_detect":	"Tempo para Detectar",
+		"reason.below_minimum":		"Abaixo do Mínimo",
+		"reason.above_maximum":		"Acima do Máximo",
+		"excluded.true":		"Sim",
+		"excluded.false":		"Não",
+	},
+	langES: {
+		"sheet.events":			"Eventos",
+		"sheet.resolutions":		"Resoluciones",
+		"sheet.open_issues":		"Issues Abiertos",
+		"heading.issue_id":		"Id del Issue",
+		"heading.issue_status":		"Estado del Issue",
+		"heading.project_name":		"Nombre del Proyecto",
+		"heading.environment":		"Entorno",
+		"heading.owner":		"Responsable",
+		"heading.time_to_resolve":	"Tiempo de Resolución en Segundos",
+		"heading.assigned_to_resolved":	"De la Asignación a la Resolución en Segundos",
+		"heading.mttr_target":		"Objetivo de MTTR en Segundos",
+		"heading.event_id":		"Id del Evento",
+		"heading.created_at":		"Creado el",
+		"heading.duration":		"Duración en Segundos",
+		"heading.weekday":		"Día de la Semana",
+		"heading.date":			"Fecha",
+		"heading.open_issues":		"Issues Abiertos",
+		"weekday.Sunday":		"Domingo",
+		"weekday.Monday":		"Lunes",
+		"weekday.Tuesday":		"Martes",
+		"weekday.Wednesday":		"Miércoles",
+		"weekday.Thursday":		"Jueves",
+		"weekday.Friday":		"Viernes",
+		"weekday.Saturday":		"Sábado",
+		"summary.title":		"Informe de confiabilidad de Sentry, %s",
+		"summary.totals":		"%d proyectos, %d issues, %d eventos",
+		"summary.assigned_mttr":	"MTTR tras la asignación: %s",
+		"summary.reopen_rate":		"Tasa de reapertura: %.1f%%",
+		"summary.compliance":		"Cumplimiento del objetivo de MTTR: %.1f%%",
+		"summary.group_reopened":	", %.1f%% reabiertos",
+		"summary.group_compliance":	", %.1f%% dentro del objetivo de %s",
+		"summary.group_breaches":	", %d incumplidos ⚠️",
+		"summary.resolution_source":	"Resueltos vía %s: %.1f%%",
+		"summary.delta":		" (%s respecto a la referencia)",
+		"summary.bursts":		"El MTBF agrupó %d eventos a menos de %s del anterior",
+		"summary.diagnostics":		"%d duraciones fuera de los límites, %d excluidas",
+		"sheet.diagnostics":		"Diagnóstico",
+		"heading.kind":			"Tipo",
+		"heading.reason":		"Motivo",
+		"heading.excluded":		"Excluida",
+		"duration.time_to_repair":	"Tiempo de Reparación",
+		"duration.assigned_to_resolved":	"De la Asignación a la Resolución",
+		"duration.time_to_detect":	"Tiempo de Detección",
+		"reason.below_minimum":		"Por Debajo del Mínimo",
+		"reason.above_maximum":		"Por Encima del Máximo",
+		"excluded.true":		"Sí",
+		"excluded.false":		"No",
+	},
+}
+
+// translate returns the label of a key in a language, the key itself when
+// no catalog has it
+func translate(lang string, key string) string {
+	if text, ok := catalogs[lang][key]; ok {
+		return text
+	}
+
+	if text, ok := catalogs[langEN][key]; ok {
+		return text
+	}
+
+	return key
+}
+
+func (c *Calculator) text(key string) string {
+	return translate(c.Options.Lang, key)
+}
+
+func (c *Calculator) weekday(weekday time.Weekday) string {
+	return c.text("weekday." + weekday.String())
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tealeg/xlsx"
+)
+
+// Locale tells how dates are written in the spreadsheets, numbers are numeric
+// cells that the spreadsheet shows with the separators of the machine opening
+// them
+type Locale struct {
+	DateFormat	string
+	DayFormat	string
+}
+
+// With no locale dates stay as Sentry returns them
+var locales = map[string]Locale{
+	"en-US": {DateFormat: "01/02/2006 15:04:05", DayFormat: "01/02/2006"},
+	"en-GB": {DateFormat: "02/01/2006 15:04:05", DayFormat: "02/01/2006"},
+	"pt-BR": {DateFormat: "02/01/2006 15:04:05", DayFormat: "02/01/2006"},
+	"es-ES": {DateFormat: "02/01/2006 15:04:05", DayFormat: "02/01/2006"},
+	"de-DE": {DateFormat: "02.01.2006 15:04:05", DayFormat: "02.01.2006"},
+}
+
+// Number format of the duration and count cells, grouped by the viewer
+const numberFormat = "#,##0"
+
+func localeNames() (names []string) {
+	for name := range locales {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return
+}
+
+// setSecondsCell writes a duration in seconds as a number
+func setSecondsCell(cell *xlsx.Cell, seconds float64) {
+	cell.SetFloatWithFormat(seconds, numberFormat)
+}
+
+func setCountCell(cell *xlsx.Cell, count int) {
+	cell.SetFloatWithFormat(float64(count), numberFormat)
+}
+
+// formatDateCell writes a Sentry timestamp in the date format of the locale, in UTC
+func (c *Calculator) formatDateCell(date string) string {
+	locale, ok := locales[c.Options.Locale]
+	if !ok {
+		return date
+	}
+
+	parsed, err := time.Parse(timeFormat, date)
+	if err != nil {
+		return date
+	}
+
+	return parsed.UTC().Format(locale.DateFormat)
+}
+
+// formatDayCell writes a day like 2006-01-02 in the day format of the locale
+func (c *Calculator) formatDayCell(day string) string {
+	locale, ok := locales[c.Options.Locale]
+	if !ok {
+		return day
+	}
+
+	parsed, err := time.Parse(dayFormat, day)
+	if err != nil {
+		return day
+	}
+
+	return parsed.Format(locale.DayFormat)
+}
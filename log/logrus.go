@@ -12,6 +12,8 @@ func NewLogrus() *logrus.Logger {
 	log := logrus.New()
 	log.Level = getLogLevel()
 	log.Formatter = new(prefixed.TextFormatter)
+	// stdout is reserved for machine-readable output
+	log.Out = os.Stderr
 
 	return log
 }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"time"
+)
+
+const newRelicMetricURL = "https://metric-api.newrelic.com/metric/v1"
+
+// newRelicSink sends gauges to the New Relic Metric API, the totals are tagged
+// with scope "total" and every project gets its own entity tags
+type newRelicSink struct {
+	APIKey		string
+	URL		string
+}
+
+func newNewRelicSink() *newRelicSink {
+	sink := &newRelicSink{
+		APIKey: os.Getenv("NEW_RELIC_API_KEY"),
+		URL: os.Getenv("NEW_RELIC_METRIC_URL"),
+	}
+
+	// EU accounts use https://metric-api.eu.newrelic.com/metric/v1
+	if sink.URL == "" {
+		sink.URL = newRelicMetricURL
+	}
+
+	return sink
+}
+
+func (s *newRelicSink) Name() string {
+	return "New Relic"
+}
+
+func (s *newRelicSink) Publish(report Report) error {
+	var gauges []map[string]interface{}
+
+	gauge := func(name string, value *float64, attributes map[string]interface{}) {
+		if value != nil {
+			gauges = append(gauges, map[string]interface{}{
+				"name": name,
+				"type": "gauge",
+				"value": *value,
+				"attributes": attributes,
+			})
+		}
+	}
+
+	total := map[string]interface{}{"scope": "total"}
+	gauge("sentry.mttr", report.MTTR, total)
+	gauge("sentry.mtbf", report.MTBF, total)
+
+	for _, project := range report.PerProject {
+		attributes := map[string]interface{}{
+			"scope": "project",
+			"sentry.organization": project.Organization,
+			"sentry.project": project.Project,
+			"entity.name": project.Name,
+		}
+
+		gauge("sentry.mttr", project.MTTR, attributes)
+		gauge("sentry.mtbf", project.MTBF, attributes)
+	}
+
+	body := []map[string]interface{}{{
+		"common": map[string]interface{}{
+			"timestamp": time.Now().UnixNano() / int64(time.Millisecond),
+			"attributes": map[string]interface{}{
+				"service.name": binaryName,
+				"service.version": version,
+				"unit": "seconds",
+			},
+		},
+		"metrics": gauges,
+	}}
+
+	return postJSON(s.URL, map[string]string{"Api-Key": s.APIKey}, body)
+}
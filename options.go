@@ -0,0 +1,251 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Options holds the command line configuration of a run
+type Options struct {
+	Command		string
+	Args		[]string
+	Version		bool
+	Quiet		bool
+	Format		string
+	Lang		string
+	Locale		string
+	MaxMTTR		time.Duration
+	MinMTBF		time.Duration
+	SkipMTTR	bool
+	SkipMTBF	bool
+	ExcludeAutoResolved	bool
+	RepairStart	[]string
+	RepairEnd	[]string
+	ClockStart	string
+	BurstWindow	time.Duration
+	ExcludeZeroGaps	bool
+	MinDuration	time.Duration
+	MaxDuration	time.Duration
+	OutOfBounds	string
+	Since		string
+	Timings		bool
+	Bundle		string
+	MTTD		bool
+	MaxEventsPerIssue	int
+	ProjectsConcurrency	int
+	IssuesConcurrency	int
+	DetailsConcurrency	int
+	EventsConcurrency	int
+	EventsMemory	int
+	Shard		string
+	ShardIndex	int
+	ShardCount	int
+	Dump		string
+	MTTRTargets	map[string]time.Duration
+	SentryHeaders	map[string]string
+	UserAgent	string
+	Encrypt		bool
+	KeyFile		string
+	SignKey		string
+	Baseline	string
+	History		string
+	Listen		string
+	Interval	time.Duration
+}
+
+const (
+	formatXLSX	= "xlsx"
+	formatJSON	= "json"
+	clockActivity	= "activity"
+	clockRelease	= "release"
+)
+
+// Periods as Sentry takes them in statsPeriod, e.g. 90d or 12h
+var periodPattern = regexp.MustCompile(`^[0-9]+[smhdw]$`)
+
+func parseOptions() (options *Options, err error) {
+	options = new(Options)
+
+	// Bad flags are configuration errors, the default handler would exit with 2,
+	// the caller reports them so the flag package must not print them too
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	flag.CommandLine.SetOutput(ioutil.Discard)
+
+	flag.BoolVar(&options.Version, "version", false, "Print version and build information")
+	flag.BoolVar(&options.Quiet, "quiet", false, "Only log warnings and errors, logs always go to stderr")
+	flag.StringVar(&options.Format, "format", formatXLSX, "Output format: 'xlsx' writes spreadsheets, 'json' prints the report to stdout")
+	flag.StringVar(&options.Lang, "lang", langEN, "Language of the spreadsheets and notifications: 'en', 'pt-BR' or 'es'")
+	flag.StringVar(&options.Locale, "locale", "", "Date format of the spreadsheets, e.g. 'pt-BR', dates as Sentry returns them when empty")
+	flag.DurationVar(&options.MaxMTTR, "max-mttr", 0, "Exit with 3 when MTTR is above this duration, e.g. 24h")
+	flag.DurationVar(&options.MinMTBF, "min-mtbf", 0, "Exit with 3 when MTBF is below this duration, e.g. 1h")
+
+	flag.BoolVar(&options.SkipMTTR, "skip-mttr", false, "Don't compute MTTR, skips the issue detail calls")
+	flag.BoolVar(&options.SkipMTBF, "skip-mtbf", false, "Don't compute MTBF, skips fetching events")
+	flag.BoolVar(&options.ExcludeAutoResolved, "exclude-auto-resolved", false, "Leave issues Sentry resolved by itself after a period of silence out of MTTR")
+	repairStart := flag.String("repair-start", "first_seen", "Activity types starting the repair clock, comma separated, * matches any text, e.g. first_seen,assigned")
+	repairEnd := flag.String("repair-end", "set_resolved*", "Activity types stopping the repair clock at the first one after a start, comma separated, * matches any text, a regression before it leaves the repair uncounted")
+	flag.StringVar(&options.ClockStart, "clock-start", clockActivity, "Start of the repair clock: 'activity' uses --repair-start, 'release' the deploy of the first release of each issue")
+	flag.BoolVar(&options.MTTD, "mttd", false, "Compute MTTD from the deploys of the first release of each issue, fetches the detail of every issue")
+	flag.DurationVar(&options.BurstWindow, "burst-window", 0, "Count events within this duration of the previous one as the same failure in MTBF, e.g. 30s")
+	flag.BoolVar(&options.ExcludeZeroGaps, "exclude-zero-gaps", false, "Count events sharing the timestamp of the previous one as the same failure in MTBF")
+	flag.DurationVar(&options.MinDuration, "min-duration", 0, "Shortest plausible repair, assignment or detection time, shorter ones are listed in the diagnostics")
+	flag.DurationVar(&options.MaxDuration, "max-duration", 0, "Longest plausible repair, assignment or detection time, e.g. 8760h, 0 has no limit")
+	flag.StringVar(&options.OutOfBounds, "out-of-bounds", boundsFlag, "What to do with durations out of bounds: 'flag' keeps them in the metrics, 'exclude' leaves them out")
+	flag.StringVar(&options.Since, "since", "90d", "Only crawl the issues seen within this period, e.g. 30d or 12h, Sentry keeps 90 days of events by default")
+	flag.IntVar(&options.MaxEventsPerIssue, "max-events-per-issue", 0, "Only fetch the most recent N events of each issue for MTBF, 0 fetches all")
+	flag.IntVar(&options.ProjectsConcurrency, "projects-concurrency", 2, "Organizations whose projects are listed at the same time")
+	flag.IntVar(&options.IssuesConcurrency, "issues-concurrency", 4, "Issue listings running at the same time")
+	flag.IntVar(&options.DetailsConcurrency, "details-concurrency", 8, "Issue detail calls running at the same time")
+	flag.IntVar(&options.EventsConcurrency, "events-concurrency", 8, "Issues whose events are fetched at the same time")
+	flag.IntVar(&options.EventsMemory, "events-memory", 256, "Megabytes of events kept in memory for the MTBF spreadsheet before spilling to disk")
+	flag.StringVar(&options.Shard, "shard", "", "Only crawl the projects of shard i out of n, e.g. 2/5")
+	flag.BoolVar(&options.Encrypt, "encrypt", false, "Encrypt the reports with AES-256-GCM, keyed by OUTPUT_PASSPHRASE or --key-file, see the decrypt command")
+	flag.StringVar(&options.KeyFile, "key-file", "", "File with the 32 byte key, raw or in hex, used instead of OUTPUT_PASSPHRASE")
+	flag.StringVar(&options.SignKey, "sign-key", "", "Sign a manifest of the artifacts with this ed25519 private key in PKCS #8 PEM")
+	flag.StringVar(&options.Baseline, "baseline", "", "Annotate the metrics with their change since this previous JSON report")
+	flag.StringVar(&options.History, "history", "", "Append the report of every run to this JSONL file, queried by the serve command")
+	flag.StringVar(&options.Listen, "listen", "127.0.0.1:8080", "Address the serve command listens on, POST /runs has no authentication so mind exposing it")
+	flag.DurationVar(&options.Interval, "interval", 0, "Run a calculation this often while serving, e.g. 1h, 0 only serves the history")
+	flag.BoolVar(&options.Timings, "timings", false, "Print the wall time, requests, retries and throughput of every phase to stderr at the end of the run")
+	flag.StringVar(&options.Bundle, "bundle", "", "Archive the dataset, configuration, version and outputs into this tar.gz, see the verify command")
+	flag.StringVar(&options.Dump, "dump", "", "Write the crawled dataset to this file, encrypted next to it with --encrypt, see the merge command")
+
+	err = flag.CommandLine.Parse(os.Args[1:])
+	if err != nil {
+		return
+	}
+
+	// Flags are also accepted after the command, e.g. "version --quiet"
+	if flag.NArg() > 0 {
+		options.Command = flag.Arg(0)
+
+		err = flag.CommandLine.Parse(flag.Args()[1:])
+		if err != nil {
+			return
+		}
+
+		options.Args = flag.Args()
+	}
+
+	if options.Format != formatXLSX && options.Format != formatJSON {
+		err = &configError{fmt.Sprintf("Unknown format '%s'.", options.Format)}
+	}
+
+	if _, ok := catalogs[options.Lang]; !ok {
+		err = &configError{fmt.Sprintf("Unknown language '%s'.", options.Lang)}
+	}
+
+	if _, ok := locales[options.Locale]; options.Locale != "" && !ok {
+		err = &configError{fmt.Sprintf("Unknown locale '%s', use one of %s.", options.Locale, strings.Join(localeNames(), ", "))}
+	}
+
+	if options.ClockStart != clockActivity && options.ClockStart != clockRelease {
+		err = &configError{fmt.Sprintf("Unknown clock start '%s', use 'activity' or 'release'.", options.ClockStart)}
+	}
+
+	options.RepairStart = splitActivityTypes(*repairStart)
+	options.RepairEnd = splitActivityTypes(*repairEnd)
+
+	for _, pattern := range append(append([]string{}, options.RepairStart...), options.RepairEnd...) {
+		if _, matchErr := path.Match(pattern, ""); matchErr != nil {
+			err = &configError{fmt.Sprintf("Invalid activity type pattern '%s'.", pattern)}
+		}
+	}
+
+	if len(options.RepairStart) == 0 || len(options.RepairEnd) == 0 {
+		err = &configError{"--repair-start and --repair-end need at least one activity type."}
+	}
+
+	if options.OutOfBounds != boundsFlag && options.OutOfBounds != boundsExclude {
+		err = &configError{fmt.Sprintf("Unknown --out-of-bounds '%s', use 'flag' or 'exclude'.", options.OutOfBounds)}
+	}
+
+	if options.MaxDuration < 0 || (options.MaxDuration > 0 && options.MaxDuration < options.MinDuration) {
+		err = &configError{"--max-duration must be positive and above --min-duration."}
+	}
+
+	if options.BurstWindow < 0 {
+		err = &configError{"--burst-window can't be negative."}
+	}
+
+	if !periodPattern.MatchString(options.Since) {
+		err = &configError{fmt.Sprintf("Invalid --since '%s', use a number followed by s, m, h, d or w.", options.Since)}
+	}
+
+	if options.MaxEventsPerIssue < 0 {
+		err = &configError{"--max-events-per-issue can't be negative."}
+	}
+
+	if options.ProjectsConcurrency < 1 || options.IssuesConcurrency < 1 || options.DetailsConcurrency < 1 || options.EventsConcurrency < 1 {
+		err = &configError{"Concurrency flags must be at least 1."}
+	}
+
+	if options.EventsMemory < 1 {
+		err = &configError{"--events-memory must be at least 1."}
+	}
+
+	if options.Shard != "" {
+		_, scanErr := fmt.Sscanf(options.Shard, "%d/%d", &options.ShardIndex, &options.ShardCount)
+		if scanErr != nil || options.ShardIndex < 1 || options.ShardIndex > options.ShardCount {
+			err = &configError{fmt.Sprintf("Invalid shard '%s', use i/n with 1 <= i <= n.", options.Shard)}
+		}
+	}
+
+	// Targets are per project so they live in the environment rather than
+	// flags, they hold for each repair of an issue rather than their sum
+	targets, targetsErr := parseTargets(os.Getenv("MTTR_TARGETS"))
+	if targetsErr != nil {
+		err = targetsErr
+	}
+
+	options.MTTRTargets = targets
+
+	// Gateways in front of self-hosted Sentry may need their own headers
+	options.SentryHeaders = parseHeaders(os.Getenv("SENTRY_HEADERS"))
+	options.UserAgent = os.Getenv("SENTRY_USER_AGENT")
+
+	if options.UserAgent == "" {
+		options.UserAgent = binaryName + "/" + version
+	}
+
+	for name := range options.SentryHeaders {
+		if strings.EqualFold(name, "Authorization") {
+			err = &configError{"SENTRY_HEADERS can't set Authorization, use SENTRY_TOKEN."}
+		}
+	}
+
+	if options.Interval < 0 {
+		err = &configError{"--interval can't be negative."}
+	}
+
+	if options.Encrypt && options.KeyFile == "" && os.Getenv("OUTPUT_PASSPHRASE") == "" {
+		err = &configError{"--encrypt needs OUTPUT_PASSPHRASE or --key-file."}
+	}
+
+	if options.SkipMTTR && options.SkipMTBF {
+		err = &configError{"Nothing to compute with both --skip-mttr and --skip-mtbf."}
+	}
+
+	return
+}
+
+func splitActivityTypes(value string) (types []string) {
+	for _, activityType := range strings.Split(value, ",") {
+		if activityType = strings.TrimSpace(activityType); activityType != "" {
+			types = append(types, activityType)
+		}
+	}
+
+	return
+}
+
+// collapsesBursts tells whether MTBF counts failures rather than events
+func (o *Options) collapsesBursts() bool {
+	return o.BurstWindow > 0 || o.ExcludeZeroGaps
+}
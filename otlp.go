@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// otlpSink sends gauges to an OpenTelemetry Collector over OTLP/HTTP with the
+// JSON encoding, the totals carry no project attributes
+type otlpSink struct {
+	URL		string
+	Headers		map[string]string
+}
+
+func newOTLPSink() *otlpSink {
+	sink := &otlpSink{
+		URL: os.Getenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"),
+		Headers: parseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")),
+	}
+
+	// The generic endpoint is a base URL, the signal path is appended to it
+	if sink.URL == "" {
+		sink.URL = strings.TrimRight(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "/") + "/v1/metrics"
+	}
+
+	return sink
+}
+
+func (s *otlpSink) Name() string {
+	return "OTLP"
+}
+
+func (s *otlpSink) Publish(report Report) error {
+	timestamp := fmt.Sprintf("%d", time.Now().UnixNano())
+	names := []string{}
+	points := make(map[string][]map[string]interface{})
+
+	gauge := func(name string, value *float64, attributes []map[string]interface{}) {
+		if value == nil {
+			return
+		}
+
+		if _, ok := points[name]; !ok {
+			names = append(names, name)
+		}
+
+		points[name] = append(points[name], map[string]interface{}{
+			"timeUnixNano": timestamp,
+			"asDouble": *value,
+			"attributes": attributes,
+		})
+	}
+
+	count := func(value int) *float64 {
+		number := float64(value)
+		return &number
+	}
+
+	add := func(point MetricsPoint, attributes []map[string]interface{}) {
+		gauge("sentry.mttr", point.MTTR, attributes)
+		gauge("sentry.mtbf", point.MTBF, attributes)
+		gauge("sentry.mttd", point.MTTD, attributes)
+		gauge("sentry.reopen_rate", point.ReopenRate, attributes)
+		gauge("sentry.mttr_compliance", point.Compliance, attributes)
+		gauge("sentry.issues", count(point.Issues), attributes)
+		gauge("sentry.events", count(point.Events), attributes)
+	}
+
+	total, _ := report.point("")
+	add(total, []map[string]interface{}{})
+
+	for _, project := range report.PerProject {
+		point, _ := report.point(project.Organization + "/" + project.Project)
+
+		add(point, []map[string]interface{}{
+			otlpAttribute("sentry.organization", project.Organization),
+			otlpAttribute("sentry.project", project.Project),
+		})
+	}
+
+	var metrics []map[string]interface{}
+
+	for _, name := range names {
+		metrics = append(metrics, map[string]interface{}{
+			"name": name,
+			"unit": otlpUnit(name),
+			"gauge": map[string]interface{}{"dataPoints": points[name]},
+		})
+	}
+
+	body := map[string]interface{}{
+		"resourceMetrics": []map[string]interface{}{{
+			"resource": map[string]interface{}{
+				"attributes": []map[string]interface{}{
+					otlpAttribute("service.name", binaryName),
+					otlpAttribute("service.version", version),
+				},
+			},
+			"scopeMetrics": []map[string]interface{}{{
+				"scope": map[string]interface{}{"name": binaryName, "version": version},
+				"metrics": metrics,
+			}},
+		}},
+	}
+
+	return postJSON(s.URL, s.Headers, body)
+}
+
+func otlpAttribute(key string, value string) map[string]interface{} {
+	return map[string]interface{}{
+		"key": key,
+		"value": map[string]interface{}{"stringValue": value},
+	}
+}
+
+// otlpUnit returns the UCUM unit of a metric, rates are ratios
+func otlpUnit(name string) string {
+	switch name {
+	case "sentry.mttr", "sentry.mtbf", "sentry.mttd":
+		return "s"
+	case "sentry.reopen_rate", "sentry.mttr_compliance":
+		return "1"
+	}
+
+	return "{count}"
+}
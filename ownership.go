@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// IssueOwner is an owner Sentry suggests for an issue, e.g. "team:7"
+type IssueOwner struct {
+	Type		string `json:"type"`
+	Owner		string `json:"owner"`
+}
+
+// Assignee is who an issue was formally assigned to
+type Assignee struct {
+	Type		string `json:"type"`
+	Id		string `json:"id"`
+	Name		string `json:"name"`
+}
+
+type Team struct {
+	Id		string `json:"id"`
+	Slug		string `json:"slug"`
+	Name		string `json:"name"`
+}
+
+const unowned = "(unowned)"
+
+// Suggested owners from the most to the least trusted source
+var ownerTypes = []string{"ownershipRule", "codeowners", "suspectCommit"}
+
+func (c *Calculator) requestTeams(organization Organization, cursor string) (resp *http.Response, err error) {
+	uri := fmt.Sprintf("%s0/organizations/%s/teams/?cursor=%s", sentryURL, organization.Slug, cursor)
+
+	return c.request(timingProjects, uri)
+}
+
+func (c *Calculator) getTeams(organization Organization, cursor string) (teams []Team, err error) {
+	resp, err := c.requestTeams(organization, cursor)
+	if err != nil {
+		return
+	}
+
+	nextCursor, more, err := c.readPage(resp, &teams)
+	if err != nil || !more {
+		return
+	}
+
+	nextTeams, err := c.getTeams(organization, nextCursor)
+	teams = append(teams, nextTeams...)
+
+	return
+}
+
+func (c *Calculator) addTeams(teams []Team) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.teams == nil {
+		c.teams = make(map[string]Team)
+	}
+
+	for _, team := range teams {
+		c.teams[team.Id] = team
+	}
+}
+
+// issueOwner attributes an issue to the owner suggested by the ownership
+// rules, code owners or suspect commits of its project, falling back to the
+// assignee for issues no rule matches
+func (c *Calculator) issueOwner(issue Issue) string {
+	for _, ownerType := range ownerTypes {
+		for _, owner := range issue.Owners {
+			if owner.Type == ownerType {
+				return c.ownerName(owner.Owner, issue.AssignedTo)
+			}
+		}
+	}
+
+	if issue.AssignedTo != nil {
+		return c.ownerName(issue.AssignedTo.Type+":"+issue.AssignedTo.Id, issue.AssignedTo)
+	}
+
+	return unowned
+}
+
+// ownerName turns an actor like "team:7" into a readable name, teams are known
+// by slug and users only by name when they are also the assignee
+func (c *Calculator) ownerName(actor string, assignee *Assignee) string {
+	kind, id := actor, ""
+	if i := strings.Index(actor, ":"); i >= 0 {
+		kind, id = actor[:i], actor[i+1:]
+	}
+
+	if assignee != nil && assignee.Type == kind && assignee.Id == id && assignee.Name != "" {
+		if kind == "team" {
+			return "#" + assignee.Name
+		}
+
+		return assignee.Name
+	}
+
+	if kind == "team" {
+		c.mutex.Lock()
+		team, ok := c.teams[id]
+		c.mutex.Unlock()
+
+		if ok {
+			return "#" + team.Slug
+		}
+	}
+
+	return actor
+}
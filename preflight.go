@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Scopes the token needs to crawl organizations, projects, issues and events
+var requiredScopes = []string{"org:read", "project:read", "event:read"}
+
+type apiRoot struct {
+	Auth		*apiAuth	`json:"auth"`
+}
+
+type apiAuth struct {
+	Scopes		[]string	`json:"scopes"`
+}
+
+// preflight validates the token against the API root before any crawling happens
+func (c *Calculator) preflight() error {
+	uri := fmt.Sprintf("%s0/", sentryURL)
+
+	resp, err := c.request(timingPreflight, uri)
+	if err != nil {
+		return err
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "application/json") {
+		resp.Body.Close()
+		return &configError{fmt.Sprintf("GET %s answered with '%s' instead of JSON, check the Sentry URL and any proxy in front of it", uri, contentType)}
+	}
+
+	var root apiRoot
+
+	_, _, err = c.readPage(resp, &root)
+	if err != nil {
+		return &configError{err.Error()}
+	}
+
+	if root.Auth == nil {
+		return &configError{"Sentry didn't recognize the token, check SENTRY_TOKEN"}
+	}
+
+	var missing []string
+
+	for _, scope := range requiredScopes {
+		if !scopeGranted(root.Auth.Scopes, scope) {
+			missing = append(missing, scope)
+		}
+	}
+
+	if len(missing) > 0 {
+		return &configError{fmt.Sprintf("The token is missing the scopes %s, create one with %s at Settings > Account > API > Auth Tokens", strings.Join(missing, ", "), strings.Join(requiredScopes, ", "))}
+	}
+
+	c.Log.Debug(fmt.Sprintf("Token scopes: %s", strings.Join(root.Auth.Scopes, ", ")))
+
+	return nil
+}
+
+// scopeGranted tells whether scope is granted, write and admin levels imply read
+func scopeGranted(scopes []string, scope string) bool {
+	resource := strings.SplitN(scope, ":", 2)[0]
+
+	for _, granted := range scopes {
+		if granted == scope || granted == resource+":write" || granted == resource+":admin" {
+			return true
+		}
+	}
+
+	return false
+}
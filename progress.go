@@ -0,0 +1,81 @@
+package main
+
+import (
+	"sync"
+)
+
+// Phases of a run as shown by the progress views
+const (
+	phaseCrawling	= "crawling"
+	phaseReplaying	= "replaying"
+	phaseWriting	= "writing"
+	phaseDone	= "done"
+)
+
+// Progress is a snapshot of how far a run got, counts are by kind of item
+// fetched, e.g. "issues", and by request
+type Progress struct {
+	Phase		string		`json:"phase"`
+	Counts		map[string]int	`json:"counts"`
+	Failures	int		`json:"failures"`
+	Partial		*MetricsPoint	`json:"partial,omitempty"`
+}
+
+// progress is updated by the pipeline and polled by the views, it has its
+// own lock so fetches don't wait on the calculator
+type progress struct {
+	phase		string
+	counts		map[string]int
+	spans		map[string]*phaseSpan
+	mutex		sync.Mutex
+}
+
+func (c *Calculator) setPhase(phase string) {
+	c.progress.mutex.Lock()
+	defer c.progress.mutex.Unlock()
+
+	c.progress.phase = phase
+}
+
+func (c *Calculator) advance(kind string, n int) {
+	c.progress.mutex.Lock()
+	defer c.progress.mutex.Unlock()
+
+	if c.progress.counts == nil {
+		c.progress.counts = make(map[string]int)
+	}
+
+	c.progress.counts[kind] += n
+}
+
+// Progress returns a copy of the progress of the run, safe to call while it runs
+func (c *Calculator) Progress() Progress {
+	c.progress.mutex.Lock()
+	snapshot := Progress{Phase: c.progress.phase, Counts: make(map[string]int)}
+
+	for kind, count := range c.progress.counts {
+		snapshot.Counts[kind] = count
+	}
+
+	c.progress.mutex.Unlock()
+
+	c.mutex.Lock()
+	snapshot.Failures = c.failures
+	stats := c.stats
+	c.mutex.Unlock()
+
+	if stats != nil {
+		partial := stats.partial()
+		snapshot.Partial = &partial
+	}
+
+	return snapshot
+}
+
+// lastReport returns the report of the last calculation, if it finished
+func (c *Calculator) lastReport() *Report {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.report
+}
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Report is the machine-readable result of a run, durations are in seconds
+// and skipped metrics are left out. AssignedMTTR only counts the time from
+// assignment to resolution, over the repairs MTTR measures that had someone
+// assigned.
+// MTTD goes from the deploy of the first release of an issue to first seen
+type Report struct {
+	Metadata	Metadata	`json:"metadata"`
+	MTTR		*float64	`json:"mttr,omitempty"`
+	AssignedMTTR	*float64	`json:"assigned_mttr,omitempty"`
+	ReopenRate	*float64	`json:"reopen_rate,omitempty"`
+	Compliance	*float64	`json:"compliance,omitempty"`
+	MTTD		*float64	`json:"mttd,omitempty"`
+	MTBF		*float64	`json:"mtbf,omitempty"`
+	Projects	int	`json:"projects"`
+	Issues		int	`json:"issues"`
+	Events		int	`json:"events"`
+	PerProject	[]ProjectMetrics	`json:"per_project,omitempty"`
+	PerEnvironment	[]EnvironmentMetrics	`json:"per_environment,omitempty"`
+	PerOwner	[]OwnerMetrics	`json:"per_owner,omitempty"`
+	PerResolutionSource	[]ResolutionSourceMetrics	`json:"per_resolution_source,omitempty"`
+	Heatmap		*Heatmap	`json:"heatmap,omitempty"`
+	Bursts		*Bursts	`json:"bursts,omitempty"`
+	Diagnostics	[]Diagnostic	`json:"diagnostics,omitempty"`
+	Baseline	*Baseline	`json:"baseline,omitempty"`
+}
+
+// Bursts tells how MTBF collapsed the events close to the previous one into
+// the same failure
+type Bursts struct {
+	Window		float64	`json:"window"`
+	ExcludeZeroGaps	bool	`json:"exclude_zero_gaps"`
+	Collapsed	int	`json:"collapsed"`
+}
+
+// ProjectMetrics breaks the metrics of a report down to a project, along with
+// the daily count of its open issues
+type ProjectMetrics struct {
+	Organization	string	`json:"organization"`
+	Project		string	`json:"project"`
+	Name		string	`json:"name"`
+	MTTR		*float64	`json:"mttr,omitempty"`
+	AssignedMTTR	*float64	`json:"assigned_mttr,omitempty"`
+	ReopenRate	*float64	`json:"reopen_rate,omitempty"`
+	MTTRTarget	*float64	`json:"mttr_target,omitempty"`
+	Compliance	*float64	`json:"compliance,omitempty"`
+	Breaches	int	`json:"breaches,omitempty"`
+	MTTD		*float64	`json:"mttd,omitempty"`
+	MTBF		*float64	`json:"mtbf,omitempty"`
+	Issues		int	`json:"issues"`
+	Events		int	`json:"events"`
+	OpenIssues	[]DailyCount	`json:"open_issues,omitempty"`
+}
+
+// Metadata identifies the binary and the moment that produced a report
+type Metadata struct {
+	Version		string	`json:"version"`
+	Commit		string	`json:"commit"`
+	BuildDate	string	`json:"build_date"`
+	GeneratedAt	string	`json:"generated_at"`
+	Shard		string	`json:"shard,omitempty"`
+	Lang		string	`json:"lang,omitempty"`
+}
+
+func newMetadata(options *Options) Metadata {
+	return Metadata{
+		Version: version,
+		Commit: commit,
+		BuildDate: buildDate,
+		GeneratedAt: time.Now().UTC().Format(timeFormat),
+		Shard: options.Shard,
+		Lang: options.Lang,
+	}
+}
+
+// EnvironmentMetrics breaks the metrics of a report down to the environment
+// tag of the events, issues count in the environment of most of their events
+type EnvironmentMetrics struct {
+	Environment	string	`json:"environment"`
+	MTTR		*float64	`json:"mttr,omitempty"`
+	AssignedMTTR	*float64	`json:"assigned_mttr,omitempty"`
+	ReopenRate	*float64	`json:"reopen_rate,omitempty"`
+	MTTD		*float64	`json:"mttd,omitempty"`
+	MTBF		*float64	`json:"mtbf,omitempty"`
+	Issues		int	`json:"issues"`
+	Events		int	`json:"events"`
+}
+
+// OwnerMetrics breaks the metrics of a report down to the team or person
+// owning the issues, by ownership rules first and assignment otherwise
+type OwnerMetrics struct {
+	Owner		string	`json:"owner"`
+	MTTR		*float64	`json:"mttr,omitempty"`
+	AssignedMTTR	*float64	`json:"assigned_mttr,omitempty"`
+	ReopenRate	*float64	`json:"reopen_rate,omitempty"`
+	MTTD		*float64	`json:"mttd,omitempty"`
+	MTBF		*float64	`json:"mtbf,omitempty"`
+	Issues		int	`json:"issues"`
+	Events		int	`json:"events"`
+}
+
+// ResolutionSourceMetrics tells how many resolutions came from a source, e.g.
+// in_release, and how long the issues it resolved took to be repaired. Share
+// is over the resolutions, MTTR over the measured repair intervals and left
+// out when the source ended none
+type ResolutionSourceMetrics struct {
+	Source		string	`json:"source"`
+	Resolutions	int	`json:"resolutions"`
+	Share		float64	`json:"share"`
+	MTTR		*float64	`json:"mttr,omitempty"`
+}
+
+func (c *Calculator) buildReport(stats *Stats) (report Report) {
+	report = Report{
+		Metadata: newMetadata(c.Options),
+		Projects: stats.Projects,
+		Issues: stats.Total.Issues,
+		Events: stats.Total.Events,
+		Heatmap: &Heatmap{},
+		Diagnostics: stats.diagnostics(),
+	}
+
+	if !c.Options.SkipMTTR {
+		mttr := stats.Total.mttr()
+		c.Log.Info(fmt.Sprintf("MTTR: %.0f seconds", mttr))
+		report.MTTR = &mttr
+		report.Heatmap.Resolutions = stats.resolutions.rows()
+
+		assignedMTTR := stats.Total.assignedMTTR()
+		c.Log.Info(fmt.Sprintf("MTTR after assignment: %.0f seconds", assignedMTTR))
+		report.AssignedMTTR = &assignedMTTR
+
+		reopenRate := stats.Total.reopenRate()
+		c.Log.Info(fmt.Sprintf("Reopen rate: %.1f%%", reopenRate*100))
+		report.ReopenRate = &reopenRate
+
+		if stats.Total.targeted > 0 {
+			compliance := stats.Total.compliance()
+			c.Log.Info(fmt.Sprintf("MTTR target compliance: %.1f%%", compliance*100))
+			report.Compliance = &compliance
+		}
+	}
+
+	if c.Options.MTTD {
+		mttd := stats.Total.mttd()
+		c.Log.Info(fmt.Sprintf("MTTD: %.0f seconds", mttd))
+		report.MTTD = &mttd
+	}
+
+	if !c.Options.SkipMTBF {
+		mtbf := stats.Total.mtbf()
+		c.Log.Info(fmt.Sprintf("MTBF: %.0f seconds", mtbf))
+		report.MTBF = &mtbf
+		report.Heatmap.Events = stats.events.rows()
+
+		if c.Options.collapsesBursts() {
+			c.Log.Info(fmt.Sprintf("Collapsed %d events into the failure before them", stats.collapsed))
+			report.Bursts = &Bursts{Window: c.Options.BurstWindow.Seconds(), ExcludeZeroGaps: c.Options.ExcludeZeroGaps, Collapsed: stats.collapsed}
+		}
+	}
+
+	for _, project := range stats.projects() {
+		metrics := ProjectMetrics{
+			Organization: project.Project.Organization.Slug,
+			Project: project.Project.Slug,
+			Name: project.Project.Name,
+			Issues: project.Issues,
+			Events: project.Events,
+			OpenIssues: burnDown(project.openDeltas, time.Now()),
+		}
+
+		if !c.Options.SkipMTTR {
+			metrics.MTTR = sampled(project.mttr(), project.repairs > 0)
+			metrics.AssignedMTTR = sampled(project.assignedMTTR(), project.assignments > 0)
+
+			reopenRate := project.reopenRate()
+			metrics.ReopenRate = &reopenRate
+
+			target, ok := c.mttrTarget(project.Project)
+			if ok {
+				seconds := target.Seconds()
+				metrics.MTTRTarget = &seconds
+			}
+
+			if project.targeted > 0 {
+				compliance := project.compliance()
+				metrics.Compliance = &compliance
+				metrics.Breaches = project.targeted - project.withinTarget
+			}
+		}
+
+		if c.Options.MTTD {
+			metrics.MTTD = sampled(project.mttd(), project.detections > 0)
+		}
+
+		if !c.Options.SkipMTBF {
+			metrics.MTBF = sampled(project.mtbf(), project.failureGaps())
+		}
+
+		report.PerProject = append(report.PerProject, metrics)
+	}
+
+	environments := groupNames(stats.perEnvironment)
+
+	if c.Options.SkipMTBF {
+		c.Log.Warn("Environments come from the events, --skip-mtbf leaves the per environment metrics out")
+		environments = nil
+	}
+
+	for _, environment := range environments {
+		stats := stats.perEnvironment[environment]
+		metrics := EnvironmentMetrics{
+			Environment: environment,
+			Issues: stats.Issues,
+			Events: stats.Events,
+		}
+
+		if !c.Options.SkipMTTR {
+			metrics.MTTR = sampled(stats.mttr(), stats.repairs > 0)
+			metrics.AssignedMTTR = sampled(stats.assignedMTTR(), stats.assignments > 0)
+
+			reopenRate := stats.reopenRate()
+			metrics.ReopenRate = &reopenRate
+		}
+
+		if c.Options.MTTD {
+			metrics.MTTD = sampled(stats.mttd(), stats.detections > 0)
+		}
+
+		if !c.Options.SkipMTBF {
+			metrics.MTBF = sampled(stats.mtbf(), stats.failureGaps())
+		}
+
+		report.PerEnvironment = append(report.PerEnvironment, metrics)
+	}
+
+	for _, owner := range groupNames(stats.perOwner) {
+		stats := stats.perOwner[owner]
+		metrics := OwnerMetrics{
+			Owner: owner,
+			Issues: stats.Issues,
+			Events: stats.Events,
+		}
+
+		if !c.Options.SkipMTTR {
+			metrics.MTTR = sampled(stats.mttr(), stats.repairs > 0)
+			metrics.AssignedMTTR = sampled(stats.assignedMTTR(), stats.assignments > 0)
+
+			reopenRate := stats.reopenRate()
+			metrics.ReopenRate = &reopenRate
+		}
+
+		if c.Options.MTTD {
+			metrics.MTTD = sampled(stats.mttd(), stats.detections > 0)
+		}
+
+		if !c.Options.SkipMTBF {
+			metrics.MTBF = sampled(stats.mtbf(), stats.failureGaps())
+		}
+
+		report.PerOwner = append(report.PerOwner, metrics)
+	}
+
+	resolutions := 0
+	for _, source := range stats.perSource {
+		resolutions += source.Resolutions
+	}
+
+	for _, name := range stats.sources() {
+		source := stats.perSource[name]
+
+		metrics := ResolutionSourceMetrics{
+			Source: name,
+			Resolutions: source.Resolutions,
+			Share: float64(source.Resolutions) / float64(resolutions),
+		}
+
+		if source.repairs > 0 {
+			mttr := source.mttr()
+			metrics.MTTR = &mttr
+		}
+
+		report.PerResolutionSource = append(report.PerResolutionSource, metrics)
+	}
+
+	return
+}
+
+// summary is a plain text digest of the report for chat notifications
+func (r Report) summary() string {
+	var b bytes.Buffer
+
+	t := func(key string) string {
+		return translate(r.Metadata.Lang, key)
+	}
+
+	fmt.Fprintf(&b, t("summary.title")+"\n", r.Metadata.GeneratedAt)
+	fmt.Fprintf(&b, t("summary.totals")+"\n", r.Projects, r.Issues, r.Events)
+
+	if r.MTTR != nil {
+		fmt.Fprintf(&b, t("summary.mttr")+r.delta(t, "mttr")+"\n", formatSeconds(*r.MTTR))
+	}
+
+	if r.AssignedMTTR != nil {
+		fmt.Fprintf(&b, t("summary.assigned_mttr")+"\n", formatSeconds(*r.AssignedMTTR))
+	}
+
+	if r.ReopenRate != nil {
+		fmt.Fprintf(&b, t("summary.reopen_rate")+"\n", *r.ReopenRate*100)
+	}
+
+	if r.Compliance != nil {
+		fmt.Fprintf(&b, t("summary.compliance")+"\n", *r.Compliance*100)
+	}
+
+	if r.MTTD != nil {
+		fmt.Fprintf(&b, t("summary.mttd")+"\n", formatSeconds(*r.MTTD))
+	}
+
+	if r.MTBF != nil {
+		fmt.Fprintf(&b, t("summary.mtbf")+r.delta(t, "mtbf")+"\n", formatSeconds(*r.MTBF))
+	}
+
+	if r.Bursts != nil {
+		fmt.Fprintf(&b, t("summary.bursts")+"\n", r.Bursts.Collapsed, formatSeconds(r.Bursts.Window))
+	}
+
+	if len(r.Diagnostics) > 0 {
+		excluded := 0
+
+		for _, diagnostic := range r.Diagnostics {
+			if diagnostic.Excluded {
+				excluded++
+			}
+		}
+
+		fmt.Fprintf(&b, t("summary.diagnostics")+"\n", len(r.Diagnostics), excluded)
+	}
+
+	for _, project := range r.PerProject {
+		fmt.Fprintf(&b, "\n%s", project.Name)
+
+		if project.MTTR != nil {
+			fmt.Fprintf(&b, t("summary.group_mttr"), formatSeconds(*project.MTTR))
+		}
+
+		if project.ReopenRate != nil {
+			fmt.Fprintf(&b, t("summary.group_reopened"), *project.ReopenRate*100)
+		}
+
+		if project.Compliance != nil {
+			fmt.Fprintf(&b, t("summary.group_compliance"), *project.Compliance*100, formatSeconds(*project.MTTRTarget))
+		}
+
+		if project.Breaches > 0 {
+			fmt.Fprintf(&b, t("summary.group_breaches"), project.Breaches)
+		}
+
+		if project.MTBF != nil {
+			fmt.Fprintf(&b, t("summary.group_mtbf"), formatSeconds(*project.MTBF))
+		}
+	}
+
+	if len(r.PerEnvironment) > 0 {
+		fmt.Fprintf(&b, "\n")
+	}
+
+	for _, environment := range r.PerEnvironment {
+		fmt.Fprintf(&b, "\n%s", environment.Environment)
+
+		if environment.MTTR != nil {
+			fmt.Fprintf(&b, t("summary.group_mttr"), formatSeconds(*environment.MTTR))
+		}
+
+		if environment.MTBF != nil {
+			fmt.Fprintf(&b, t("summary.group_mtbf"), formatSeconds(*environment.MTBF))
+		}
+	}
+
+	if len(r.PerResolutionSource) > 0 {
+		fmt.Fprintf(&b, "\n")
+	}
+
+	for _, source := range r.PerResolutionSource {
+		fmt.Fprintf(&b, "\n"+t("summary.resolution_source"), source.Source, source.Share*100)
+
+		if source.MTTR != nil {
+			fmt.Fprintf(&b, t("summary.group_mttr"), formatSeconds(*source.MTTR))
+		}
+	}
+
+	if len(r.PerOwner) > 0 {
+		fmt.Fprintf(&b, "\n")
+	}
+
+	for _, owner := range r.PerOwner {
+		fmt.Fprintf(&b, "\n%s", owner.Owner)
+
+		if owner.MTTR != nil {
+			fmt.Fprintf(&b, t("summary.group_mttr"), formatSeconds(*owner.MTTR))
+		}
+
+		if owner.MTBF != nil {
+			fmt.Fprintf(&b, t("summary.group_mtbf"), formatSeconds(*owner.MTBF))
+		}
+	}
+
+	return b.String()
+}
+
+// delta describes the change of a duration since the baseline, if any
+func (r Report) delta(t func(string) string, path string) string {
+	if r.Baseline == nil {
+		return ""
+	}
+
+	delta, ok := r.Baseline.Deltas[path]
+	if !ok {
+		return ""
+	}
+
+	sign := ""
+	if delta >= 0 {
+		sign = "+"
+	}
+
+	return strings.Replace(t("summary.delta"), "%s", sign+formatSeconds(delta), 1)
+}
+
+// formatSeconds renders a duration in seconds as e.g. 1h2m3s
+func formatSeconds(seconds float64) string {
+	return (time.Duration(seconds) * time.Second).String()
+}
+
+func (c *Calculator) writeReport(w io.Writer, report Report) {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	err := encoder.Encode(report)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Where the fixes come from, by the activity that resolved the issue
+const (
+	sourceManual	= "manual"
+	sourceInRelease	= "in_release"
+	sourceInCommit	= "in_commit"
+	sourceAuto	= "auto"
+)
+
+// resolutionSource classifies a resolution activity, resolving in the next
+// release is recorded as in release and pull requests count as commits
+func resolutionSource(activityType string) string {
+	switch activityType {
+	case "set_resolved_in_release":
+		return sourceInRelease
+	case "set_resolved_in_commit", "set_resolved_in_pull_request":
+		return sourceInCommit
+	case autoResolved:
+		return sourceAuto
+	default:
+		return sourceManual
+	}
+}
+
+// calcResolutions counts the resolutions of an issue by source and buckets
+// the repairs into the heatmap
+func (c *Calculator) calcResolutions(issue Issue, stats *Stats) {
+	for _, activity := range issue.Activity {
+		if !isResolution(activity.Type) {
+			continue
+		}
+
+		date, err := time.Parse(timeFormat, activity.DateCreated)
+		if err != nil {
+			c.Log.Warn(fmt.Sprintf("Activity #%s dropped, invalid date: %v", activity.Id, err))
+			continue
+		}
+
+		stats.addResolution(resolutionSource(activity.Type), date, c.isRepair(activity.Type))
+	}
+}
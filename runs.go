@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const progressInterval = 500 * time.Millisecond
+
+// Finished runs are forgotten beyond the most recent ones, a long running
+// server would grow without end otherwise
+const keptRuns = 50
+
+// apiRun is a calculation started through the API
+type apiRun struct {
+	Id		string		`json:"id"`
+	StartedAt	string		`json:"started_at"`
+	Progress	Progress	`json:"progress"`
+	ExitCode	*int		`json:"exit_code,omitempty"`
+	calculator	*Calculator
+	done		chan struct{}
+}
+
+// runs keeps the runs started through the API or on schedule, one at a time
+// since runs share the output files and the sinks
+type runs struct {
+	server		*Calculator
+	byId		map[string]*apiRun
+	// order lists the kept runs, oldest first
+	order		[]string
+	current		*apiRun
+	next		int
+	mutex		sync.Mutex
+}
+
+func newRuns(server *Calculator) *runs {
+	return &runs{server: server, byId: make(map[string]*apiRun)}
+}
+
+func (r *runs) start() (started *apiRun, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.current != nil {
+		return nil, fmt.Errorf("Run %s is in progress.", r.current.Id)
+	}
+
+	r.next++
+
+	calculator := NewCalculator(r.server.Options)
+	calculator.history = r.server.history
+
+	started = &apiRun{
+		Id: fmt.Sprintf("%d", r.next),
+		StartedAt: time.Now().UTC().Format(timeFormat),
+		calculator: calculator,
+		done: make(chan struct{}),
+	}
+
+	r.byId[started.Id] = started
+	r.order = append(r.order, started.Id)
+	r.current = started
+
+	// The run in progress is the newest, only finished ones are forgotten
+	for len(r.order) > keptRuns {
+		delete(r.byId, r.order[0])
+		r.order = r.order[1:]
+	}
+
+	go func() {
+		code := r.server.runCalculator(calculator)
+
+		r.mutex.Lock()
+		started.ExitCode = &code
+		r.current = nil
+		r.mutex.Unlock()
+
+		close(started.done)
+	}()
+
+	return
+}
+
+// status returns a copy of a run with its progress up to date
+func (r *runs) status(id string) (status apiRun, ok bool) {
+	r.mutex.Lock()
+	found, ok := r.byId[id]
+	if ok {
+		status = *found
+	}
+	r.mutex.Unlock()
+
+	if ok {
+		status.Progress = status.calculator.Progress()
+	}
+
+	return
+}
+
+// runsHandler serves POST /runs, starting a run, GET /runs/{id} with its
+// status and GET /runs/{id}/events streaming its progress as server-sent
+// events until it's done
+func runsHandler(runs *runs) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/runs"), "/")
+
+		switch {
+		case path == "" && r.Method == http.MethodPost:
+			if sentryTokens == nil {
+				http.Error(w, "Runs need SENTRY_TOKEN.", http.StatusServiceUnavailable)
+				return
+			}
+
+			started, err := runs.start()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Location", "/runs/"+started.Id)
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(started)
+		case path != "" && r.Method == http.MethodGet:
+			id := strings.TrimSuffix(path, "/events")
+
+			status, ok := runs.status(id)
+			if !ok {
+				http.Error(w, fmt.Sprintf("No run %s.", id), http.StatusNotFound)
+				return
+			}
+
+			if strings.HasSuffix(path, "/events") {
+				streamRun(w, r, runs, id)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(status)
+		default:
+			http.Error(w, "Use POST /runs or GET /runs/{id}[/events].", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// streamRun sends a progress event whenever the progress of a run changed,
+// then a done event with its exit code and report
+func streamRun(w http.ResponseWriter, r *http.Request, runs *runs, id string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming isn't supported.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	var last Progress
+
+	for {
+		status, ok := runs.status(id)
+		if !ok {
+			return
+		}
+
+		if !reflect.DeepEqual(status.Progress, last) {
+			writeEvent(w, "progress", status.Progress)
+			flusher.Flush()
+			last = status.Progress
+		}
+
+		if status.ExitCode != nil {
+			writeEvent(w, "done", struct {
+				ExitCode	int	`json:"exit_code"`
+				Report		*Report	`json:"report,omitempty"`
+			}{*status.ExitCode, status.calculator.lastReport()})
+			flusher.Flush()
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-status.done:
+		case <-ticker.C:
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event string, data interface{}) {
+	content, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, content)
+}
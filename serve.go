@@ -0,0 +1,149 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"time"
+)
+
+// The dashboard is a static page reading /report and /metrics
+//
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// Serve answers the metrics API on --listen out of the history and, with an
+// --interval, runs a calculation on every tick to keep it growing
+func (c *Calculator) Serve() int {
+	if c.Options.History == "" {
+		c.Log.Error("The serve command needs --history.")
+		return exitConfig
+	}
+
+	c.history = newHistory(c.Options.History)
+	runs := newRuns(c)
+
+	// Scheduled runs take their turn with the ones started through the API
+	if c.Options.Interval > 0 {
+		go func() {
+			for {
+				c.scheduledRun(runs)
+
+				time.Sleep(c.Options.Interval)
+			}
+		}()
+	}
+
+	dashboard, err := fs.Sub(dashboardFiles, "dashboard")
+	if err != nil {
+		panic(err.Error())
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", metricsHandler(c.history))
+	mux.HandleFunc("/report", reportHandler(c.history))
+	mux.HandleFunc("/runs", runsHandler(runs))
+	mux.HandleFunc("/runs/", runsHandler(runs))
+	mux.Handle("/", http.FileServer(http.FS(dashboard)))
+
+	c.Log.Info(fmt.Sprintf("Listening on %v", c.Options.Listen))
+
+	err = http.ListenAndServe(c.Options.Listen, mux)
+	c.Log.Error(err.Error())
+
+	return exitFailure
+}
+
+// scheduledRun calculates into the history of the server, unless a run
+// started through the API is still in progress
+func (c *Calculator) scheduledRun(runs *runs) {
+	started, err := runs.start()
+	if err != nil {
+		c.Log.Warn(fmt.Sprintf("Scheduled run skipped, %v", err))
+		return
+	}
+
+	<-started.done
+
+	c.Log.Info(fmt.Sprintf("Scheduled run %s finished with %d", started.Id, *started.ExitCode))
+}
+
+// runCalculator runs a calculation of the server, a failing run must not
+// take the server down
+func (c *Calculator) runCalculator(run *Calculator) (code int) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.Log.Error(fmt.Sprintf("%v", r))
+			code = exitFailure
+		}
+	}()
+
+	return run.Start()
+}
+
+// reportHandler serves GET /report as the report of the last run
+func reportHandler(history *history) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Only GET is allowed.", http.StatusMethodNotAllowed)
+			return
+		}
+
+		report, err := history.latest()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if report == nil {
+			http.Error(w, "No runs yet.", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+// metricsHandler serves GET /metrics?project=acme/api&from=...&to=..., with
+// RFC 3339 bounds, as the series of the metrics of the runs in the history
+func metricsHandler(history *history) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Only GET is allowed.", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		var bounds [2]time.Time
+
+		for i, name := range []string{"from", "to"} {
+			if query.Get(name) == "" {
+				continue
+			}
+
+			bound, err := time.Parse(time.RFC3339, query.Get(name))
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Invalid '%s', use RFC 3339, e.g. 2006-01-02T15:04:05Z.", name), http.StatusBadRequest)
+				return
+			}
+
+			bounds[i] = bound
+		}
+
+		points, err := history.series(query.Get("project"), bounds[0], bounds[1])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		json.NewEncoder(w).Encode(struct {
+			Project		string		`json:"project,omitempty"`
+			Series		[]MetricsPoint	`json:"series"`
+		}{query.Get("project"), points})
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+const (
+	manifestFile	= "manifest.json"
+	signatureSuffix	= ".sig"
+	stdoutArtifact	= "-"
+)
+
+// Manifest lists the artifacts of a run with their digests, its detached
+// ed25519 signature verifies with e.g.
+// openssl pkeyutl -verify -pubin -inkey public.pem -rawin -in manifest.json -sigfile manifest.json.sig
+type Manifest struct {
+	Metadata	Metadata	`json:"metadata"`
+	Artifacts	[]Artifact	`json:"artifacts"`
+}
+
+// Artifact is a file written by a run, the report on stdout is named "-"
+type Artifact struct {
+	File		string	`json:"file"`
+	SHA256		string	`json:"sha256"`
+	Size		int64	`json:"size"`
+}
+
+// loadSigningKey reads an ed25519 private key in PKCS #8 PEM, as written by
+// openssl genpkey -algorithm ed25519
+func loadSigningKey(path string) (key ed25519.PrivateKey, err error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, &configError{fmt.Sprintf("Can't read signing key '%v': %v", path, err)}
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, &configError{fmt.Sprintf("Signing key '%v' isn't PEM.", path)}
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, &configError{fmt.Sprintf("Can't parse signing key '%v': %v", path, err)}
+	}
+
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, &configError{fmt.Sprintf("Signing key '%v' isn't ed25519.", path)}
+	}
+
+	return key, nil
+}
+
+// addArtifact records the content of an artifact for the manifest
+func (c *Calculator) addArtifact(file string, content []byte) {
+	c.addOutput(file)
+
+	if c.signingKey == nil {
+		return
+	}
+
+	digest := sha256.Sum256(content)
+
+	c.artifacts = append(c.artifacts, Artifact{File: file, SHA256: hex.EncodeToString(digest[:]), Size: int64(len(content))})
+}
+
+// addFileArtifact records a written file, datasets can be large so they are
+// digested as a stream
+func (c *Calculator) addFileArtifact(file string) {
+	c.addOutput(file)
+
+	if c.signingKey == nil {
+		return
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	defer f.Close()
+
+	digest := sha256.New()
+
+	size, err := io.Copy(digest, f)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	c.artifacts = append(c.artifacts, Artifact{File: file, SHA256: hex.EncodeToString(digest.Sum(nil)), Size: size})
+}
+
+// signManifest writes the manifest of the artifacts and its signature
+func (c *Calculator) signManifest(metadata Metadata) error {
+	manifest, err := json.MarshalIndent(Manifest{Metadata: metadata, Artifacts: c.artifacts}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	manifest = append(manifest, '\n')
+
+	err = ioutil.WriteFile(manifestFile, manifest, 0644)
+	if err != nil {
+		return fmt.Errorf("Can't write manifest: %v", err)
+	}
+
+	err = ioutil.WriteFile(manifestFile+signatureSuffix, ed25519.Sign(c.signingKey, manifest), 0644)
+	if err != nil {
+		return fmt.Errorf("Can't write signature: %v", err)
+	}
+
+	c.Log.Info(fmt.Sprintf("Signed %d artifacts in '%v'", len(c.artifacts), manifestFile))
+
+	return nil
+}
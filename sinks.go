@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Sink publishes the report of a run to an external service
+type Sink interface {
+	Name() string
+	Publish(report Report) error
+}
+
+// sinks returns the sinks configured through the environment
+func (c *Calculator) sinks() (sinks []Sink) {
+	if os.Getenv("STATUSPAGE_API_KEY") != "" {
+		sinks = append(sinks, newStatuspageSink())
+	}
+
+	if os.Getenv("NEW_RELIC_API_KEY") != "" {
+		sinks = append(sinks, newNewRelicSink())
+	}
+
+	if os.Getenv("AZURE_MONITOR_RESOURCE_ID") != "" {
+		sinks = append(sinks, newAzureMonitorSink())
+	}
+
+	if os.Getenv("TELEGRAM_BOT_TOKEN") != "" {
+		sinks = append(sinks, newTelegramSink())
+	}
+
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT") != "" {
+		sinks = append(sinks, newOTLPSink())
+	}
+
+	return
+}
+
+// publish sends the report to every configured sink, a failing sink doesn't
+// stop the others
+func (c *Calculator) publish(report Report) {
+	for _, sink := range c.sinks() {
+		c.Log.Debug(fmt.Sprintf("Publishing to %s", sink.Name()))
+
+		err := sink.Publish(report)
+		if err != nil {
+			c.Log.Error(fmt.Sprintf("Can't publish to %s: %v", sink.Name(), err))
+		}
+	}
+}
+
+// availability derives the expected uptime, in percent, from MTBF and MTTR
+func (r Report) availability() (float64, bool) {
+	if r.MTTR == nil || r.MTBF == nil || *r.MTTR+*r.MTBF == 0 {
+		return 0, false
+	}
+
+	return *r.MTBF / (*r.MTBF + *r.MTTR) * 100, true
+}
+
+// parseHeaders reads a "key1=value1,key2=value2" list of headers, the format
+// of the OpenTelemetry environment, values are percent encoded and keep any +
+func parseHeaders(value string) map[string]string {
+	headers := make(map[string]string)
+
+	for _, header := range strings.Split(value, ",") {
+		parts := strings.SplitN(header, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			continue
+		}
+
+		decoded, err := url.PathUnescape(strings.TrimSpace(parts[1]))
+		if err != nil {
+			decoded = strings.TrimSpace(parts[1])
+		}
+
+		headers[strings.TrimSpace(parts[0])] = decoded
+	}
+
+	return headers
+}
+
+func postJSON(uri string, headers map[string]string, body interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", uri, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("POST %s returned HTTP %d: %s", uri, resp.StatusCode, bytes.TrimSpace(message))
+	}
+
+	return nil
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Stats accumulates the metrics of a run while issues and events stream in,
+// memory stays constant unless the rows for the spreadsheets are kept, and
+// even then events spill to disk past the memory budget
+type Stats struct {
+	Projects	int
+	Total		metrics
+	Activities	[]ComputedActivity
+	KeptEvents	*eventStore
+	keepRows	bool
+	keepEvents	bool
+	collapsed	int
+	outOfBounds	[]Diagnostic
+	perProject	map[string]*projectStats
+	perEnvironment	map[string]*metrics
+	perOwner	map[string]*metrics
+	perSource	map[string]*sourceStats
+	events		heatmap
+	resolutions	heatmap
+	mutex		sync.Mutex
+}
+
+// metrics accumulates MTTR and MTBF for a group of issues and events
+type metrics struct {
+	Issues		int
+	Events		int
+	repairs		float64
+	repairTime	float64
+	assignments	float64
+	assignedTime	float64
+	detections	float64
+	resolved	int
+	reopened	int
+	targeted	int
+	withinTarget	int
+	detectionTime	float64
+	firstEvent	time.Time
+	lastEvent	time.Time
+	// Set by the pass collapsing bursts, failures start at an event far
+	// enough from the previous one
+	failures	int
+	lastFailure	time.Time
+	previousEvent	time.Time
+}
+
+type sourceStats struct {
+	Resolutions	int
+	metrics
+}
+
+type projectStats struct {
+	Project		Project
+	metrics
+	openDeltas	map[string]int
+}
+
+func newStats(keepRows bool, eventsMemory int) *Stats {
+	return &Stats{
+		keepRows: keepRows,
+		KeptEvents: newEventStore(eventsMemory),
+		perProject: make(map[string]*projectStats),
+		perEnvironment: make(map[string]*metrics),
+		perOwner: make(map[string]*metrics),
+		perSource: make(map[string]*sourceStats),
+	}
+}
+
+func (s *Stats) close() {
+	s.KeptEvents.close()
+}
+
+func (s *Stats) project(project Project) *projectStats {
+	key := project.Organization.Slug + "/" + project.Slug
+
+	stats, ok := s.perProject[key]
+	if !ok {
+		stats = &projectStats{Project: project, openDeltas: make(map[string]int)}
+		s.perProject[key] = stats
+	}
+
+	return stats
+}
+
+// projects returns the per project stats sorted by organization and slug
+func (s *Stats) projects() (projects []*projectStats) {
+	var keys []string
+
+	for key := range s.perProject {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		projects = append(projects, s.perProject[key])
+	}
+
+	return
+}
+
+func (s *Stats) environment(environment string) *metrics {
+	if environment == "" {
+		environment = noEnvironment
+	}
+
+	return group(s.perEnvironment, environment)
+}
+
+func (s *Stats) owner(owner string) *metrics {
+	if owner == "" {
+		owner = unowned
+	}
+
+	return group(s.perOwner, owner)
+}
+
+func group(groups map[string]*metrics, name string) *metrics {
+	stats, ok := groups[name]
+	if !ok {
+		stats = &metrics{}
+		groups[name] = stats
+	}
+
+	return stats
+}
+
+// groupNames returns the names of the groups seen, sorted
+func groupNames(groups map[string]*metrics) (names []string) {
+	for name := range groups {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return
+}
+
+// partial returns the totals so far, while issues and events stream in
+func (s *Stats) partial() MetricsPoint {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	mttr, mtbf := s.Total.mttr(), s.Total.mtbf()
+
+	return MetricsPoint{MTTR: &mttr, MTBF: &mtbf, Issues: s.Total.Issues, Events: s.Total.Events}
+}
+
+func (s *Stats) addProjects(projects []Project) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.Projects += len(projects)
+
+	for _, project := range projects {
+		s.project(project)
+	}
+}
+
+func (s *Stats) addIssue(issue Issue) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, m := range []*metrics{&s.Total, &s.project(issue.Project).metrics, s.environment(issue.Environment), s.owner(issue.Owner)} {
+		m.Issues++
+
+		if issue.TimeToDetect != nil {
+			m.detections++
+			m.detectionTime += *issue.TimeToDetect
+		}
+	}
+}
+
+func (s *Stats) addRepairs(activity ComputedActivity, repairs float64, repairTime float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, m := range []*metrics{&s.Total, &s.project(activity.Issue.Project).metrics, s.environment(activity.Issue.Environment), s.owner(activity.Issue.Owner)} {
+		m.addRepairs(repairs, repairTime)
+		m.addAssignments(activity.Assignments, activity.AssignedDuration)
+
+		if activity.Target > 0 {
+			m.addTargeted(!activity.Breached)
+		}
+	}
+
+	if s.keepRows {
+		s.Activities = append(s.Activities, activity)
+	}
+}
+
+func (s *Stats) addEvent(issue Issue, event Event, date time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.Total.addEvent(date)
+	s.project(issue.Project).addEvent(date)
+	s.environment(event.environment()).addEvent(date)
+	s.owner(issue.Owner).addEvent(date)
+	s.events.add(date)
+
+	if s.keepRows || s.keepEvents {
+		event.Project = issue.Project.Organization.Slug + "/" + issue.Project.Slug
+		event.Owner = issue.Owner
+		s.KeptEvents.add(event)
+	}
+}
+
+// collapseBursts goes through the events in order, counting those within the
+// window of the previous event of their group as the same failure, a zero
+// window only collapses identical timestamps
+func (s *Stats) collapseBursts(window time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.KeptEvents.each(func(event Event) {
+		date, err := time.Parse(timeFormat, event.DateCreated)
+		if err != nil {
+			return
+		}
+
+		if !s.Total.addFailure(date, window) {
+			s.collapsed++
+		}
+
+		s.perProject[event.Project].addFailure(date, window)
+		s.environment(event.environment()).addFailure(date, window)
+		s.owner(event.Owner).addFailure(date, window)
+	})
+}
+
+// addOpenInterval counts an issue as opened and resolved on the days of the
+// interval, a zero resolved means it is still open
+func (s *Stats) addOpenInterval(project Project, opened time.Time, resolved time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	deltas := s.project(project).openDeltas
+	deltas[opened.UTC().Format(dayFormat)]++
+
+	if !resolved.IsZero() {
+		deltas[resolved.UTC().Format(dayFormat)]--
+	}
+}
+
+func (s *Stats) addResolvedIssue(issue Issue, reopened bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, m := range []*metrics{&s.Total, &s.project(issue.Project).metrics, s.environment(issue.Environment), s.owner(issue.Owner)} {
+		m.resolved++
+
+		if reopened {
+			m.reopened++
+		}
+	}
+}
+
+func (s *Stats) source(source string) *sourceStats {
+	stats, ok := s.perSource[source]
+	if !ok {
+		stats = &sourceStats{}
+		s.perSource[source] = stats
+	}
+
+	return stats
+}
+
+// sources returns the names of the resolution sources seen, sorted
+func (s *Stats) sources() (sources []string) {
+	for source := range s.perSource {
+		sources = append(sources, source)
+	}
+
+	sort.Strings(sources)
+
+	return
+}
+
+// addResolution counts a resolution of the given source, only repairs land
+// in the heatmap
+func (s *Stats) addResolution(source string, date time.Time, repair bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.source(source).Resolutions++
+
+	if repair {
+		s.resolutions.add(date)
+	}
+}
+
+func (s *Stats) addSourceRepair(source string, repairTime float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.source(source).addRepairs(1, repairTime)
+}
+
+func (m *metrics) addRepairs(repairs float64, repairTime float64) {
+	m.repairs += repairs
+	m.repairTime += repairTime
+}
+
+func (m *metrics) addAssignments(assignments float64, assignedTime float64) {
+	m.assignments += assignments
+	m.assignedTime += assignedTime
+}
+
+func (m *metrics) addTargeted(withinTarget bool) {
+	m.targeted++
+
+	if withinTarget {
+		m.withinTarget++
+	}
+}
+
+func (m *metrics) addEvent(date time.Time) {
+	if m.Events == 0 || date.Before(m.firstEvent) {
+		m.firstEvent = date
+	}
+
+	if m.Events == 0 || date.After(m.lastEvent) {
+		m.lastEvent = date
+	}
+
+	m.Events++
+}
+
+// addFailure tells whether an event starts a new failure rather than being
+// part of the burst of the previous one
+func (m *metrics) addFailure(date time.Time, window time.Duration) bool {
+	defer func() {
+		m.previousEvent = date
+	}()
+
+	if m.failures > 0 && date.Sub(m.previousEvent) <= window {
+		return false
+	}
+
+	m.failures++
+	m.lastFailure = date
+
+	return true
+}
+
+func (m *metrics) mttr() float64 {
+	if m.repairs == 0 {
+		return 0
+	}
+
+	return m.repairTime / m.repairs
+}
+
+// assignedMTTR is the mean time from assignment to resolution
+func (m *metrics) assignedMTTR() float64 {
+	if m.assignments == 0 {
+		return 0
+	}
+
+	return m.assignedTime / m.assignments
+}
+
+// compliance is the share of the issues with an MTTR target resolved within it
+func (m *metrics) compliance() float64 {
+	if m.targeted == 0 {
+		return 0
+	}
+
+	return float64(m.withinTarget) / float64(m.targeted)
+}
+
+// reopenRate is the share of the resolved issues that regressed afterwards
+func (m *metrics) reopenRate() float64 {
+	if m.resolved == 0 {
+		return 0
+	}
+
+	return float64(m.reopened) / float64(m.resolved)
+}
+
+// sampled is value when there are samples behind it, groups without any
+// leave the metric out rather than report 0
+func sampled(value float64, samples bool) *float64 {
+	if !samples {
+		return nil
+	}
+
+	return &value
+}
+
+// failureGaps tells whether there are at least two failures to measure MTBF
+func (m *metrics) failureGaps() bool {
+	if m.failures > 0 {
+		return m.failures > 1
+	}
+
+	return m.Events > 1
+}
+
+// mttd is the mean time from a deploy to its issues being first seen
+func (m *metrics) mttd() float64 {
+	if m.detections == 0 {
+		return 0
+	}
+
+	return m.detectionTime / m.detections
+}
+
+// mtbf is the mean time between consecutive events, once sorted the gaps add
+// up to the time between the first and the last event, so no sort is needed
+func (m *metrics) mtbf() float64 {
+	// Collapsing bursts, the gaps add up to the time to the last failure
+	if m.failures > 0 {
+		if m.failures < 2 {
+			return 0
+		}
+
+		return m.lastFailure.Sub(m.firstEvent).Seconds() / float64(m.failures-1)
+	}
+
+	if m.Events < 2 {
+		return 0
+	}
+
+	return m.lastEvent.Sub(m.firstEvent).Seconds() / float64(m.Events-1)
+}
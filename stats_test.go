@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCollapseBursts(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	issue := Issue{Id: "1", Project: Project{Slug: "api", Organization: Organization{Slug: "acme"}}, Owner: "#backend"}
+
+	cases := []struct {
+		name		string
+		window		time.Duration
+		offsets		[]time.Duration
+		collapsed	int
+		mtbf		float64
+	}{
+		{"no burst", 30 * time.Second, []time.Duration{0, time.Hour, 3 * time.Hour}, 0, 5400},
+		{"bursts", 30 * time.Second, []time.Duration{0, 10 * time.Second, 20 * time.Second, time.Hour, time.Hour + 5*time.Second, 3 * time.Hour}, 3, 5400},
+		// Chained events stay in the burst as long as each is close to the previous one
+		{"chained", 30 * time.Second, []time.Duration{0, 25 * time.Second, 50 * time.Second, 75 * time.Second, time.Hour}, 3, 3600},
+		{"zero gaps", 0, []time.Duration{0, 0, time.Hour, time.Hour, 2 * time.Hour}, 2, 3600},
+	}
+
+	for _, c := range cases {
+		stats := newStats(false, 1024*1024)
+		stats.keepEvents = true
+
+		for i, offset := range c.offsets {
+			date := start.Add(offset)
+			stats.addEvent(issue, Event{Id: string(rune('a' + i)), DateCreated: date.Format(timeFormat)}, date)
+		}
+
+		err := stats.collapseBursts(c.window)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+
+		if stats.collapsed != c.collapsed {
+			t.Errorf("%s: collapsed %d events, want %d", c.name, stats.collapsed, c.collapsed)
+		}
+
+		if mtbf := stats.Total.mtbf(); mtbf != c.mtbf {
+			t.Errorf("%s: MTBF is %v, want %v", c.name, mtbf, c.mtbf)
+		}
+
+		if mtbf := stats.perProject["acme/api"].mtbf(); mtbf != c.mtbf {
+			t.Errorf("%s: project MTBF is %v, want %v", c.name, mtbf, c.mtbf)
+		}
+
+		stats.close()
+	}
+}
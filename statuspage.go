@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const statuspageURL = "https://api.statuspage.io/v1/"
+
+// statuspageSink submits data points to Statuspage system metrics, each value
+// goes to its own metric and metrics without an id are skipped
+type statuspageSink struct {
+	APIKey			string
+	PageId			string
+	MTTRMetricId		string
+	MTBFMetricId		string
+	AvailabilityMetricId	string
+}
+
+func newStatuspageSink() *statuspageSink {
+	return &statuspageSink{
+		APIKey: os.Getenv("STATUSPAGE_API_KEY"),
+		PageId: os.Getenv("STATUSPAGE_PAGE_ID"),
+		MTTRMetricId: os.Getenv("STATUSPAGE_MTTR_METRIC_ID"),
+		MTBFMetricId: os.Getenv("STATUSPAGE_MTBF_METRIC_ID"),
+		AvailabilityMetricId: os.Getenv("STATUSPAGE_AVAILABILITY_METRIC_ID"),
+	}
+}
+
+func (s *statuspageSink) Name() string {
+	return "Statuspage"
+}
+
+func (s *statuspageSink) Publish(report Report) error {
+	if s.PageId == "" {
+		return fmt.Errorf("STATUSPAGE_PAGE_ID is required")
+	}
+
+	timestamp := time.Now().Unix()
+
+	if report.MTTR != nil && s.MTTRMetricId != "" {
+		err := s.submit(s.MTTRMetricId, timestamp, *report.MTTR)
+		if err != nil {
+			return err
+		}
+	}
+
+	if report.MTBF != nil && s.MTBFMetricId != "" {
+		err := s.submit(s.MTBFMetricId, timestamp, *report.MTBF)
+		if err != nil {
+			return err
+		}
+	}
+
+	if availability, ok := report.availability(); ok && s.AvailabilityMetricId != "" {
+		err := s.submit(s.AvailabilityMetricId, timestamp, availability)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *statuspageSink) submit(metricId string, timestamp int64, value float64) error {
+	uri := fmt.Sprintf("%spages/%s/metrics/%s/data", statuspageURL, s.PageId, metricId)
+
+	body := map[string]interface{}{
+		"data": map[string]interface{}{
+			"timestamp": timestamp,
+			"value": value,
+		},
+	}
+
+	return postJSON(uri, map[string]string{"Authorization": "OAuth " + s.APIKey}, body)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// anyProject is the target of the projects without one of their own
+const anyProject = "*"
+
+// parseTargets reads MTTR targets like "api=24h,acme/web=4h,*=72h", projects
+// are matched by organization and slug first, then by slug alone. An issue
+// breaches its target when any of its repairs takes longer
+func parseTargets(value string) (targets map[string]time.Duration, err error) {
+	targets = make(map[string]time.Duration)
+
+	for _, target := range strings.Split(value, ",") {
+		target = strings.TrimSpace(target)
+		if target == "" {
+			continue
+		}
+
+		parts := strings.SplitN(target, "=", 2)
+		if len(parts) != 2 {
+			return nil, &configError{fmt.Sprintf("Invalid MTTR target '%s', use project=duration.", target)}
+		}
+
+		duration, parseErr := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if parseErr != nil || duration <= 0 {
+			return nil, &configError{fmt.Sprintf("Invalid MTTR target '%s', the duration must be positive, e.g. 24h.", target)}
+		}
+
+		targets[strings.TrimSpace(parts[0])] = duration
+	}
+
+	return
+}
+
+// mttrTarget returns the MTTR target of a project, if any
+func (c *Calculator) mttrTarget(project Project) (target time.Duration, ok bool) {
+	for _, key := range []string{project.Organization.Slug + "/" + project.Slug, project.Slug, anyProject} {
+		target, ok = c.Options.MTTRTargets[key]
+		if ok {
+			return
+		}
+	}
+
+	return
+}
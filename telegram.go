@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+const telegramURL = "https://api.telegram.org/"
+
+// Telegram refuses messages longer than this, counting bytes keeps under it
+const telegramMessageLimit = 4096
+
+// telegramSink posts the summary of a run to a chat through a bot
+type telegramSink struct {
+	BotToken	string
+	ChatId		string
+}
+
+func newTelegramSink() *telegramSink {
+	return &telegramSink{
+		BotToken: os.Getenv("TELEGRAM_BOT_TOKEN"),
+		ChatId: os.Getenv("TELEGRAM_CHAT_ID"),
+	}
+}
+
+func (s *telegramSink) Name() string {
+	return "Telegram"
+}
+
+func (s *telegramSink) Publish(report Report) error {
+	if s.ChatId == "" {
+		return fmt.Errorf("TELEGRAM_CHAT_ID is required")
+	}
+
+	uri := fmt.Sprintf("%sbot%s/sendMessage", telegramURL, s.BotToken)
+
+	// Summaries of many projects are sent as several messages
+	for _, text := range splitMessage(report.summary(), telegramMessageLimit) {
+		body := map[string]interface{}{
+			"chat_id": s.ChatId,
+			"text": text,
+			"disable_web_page_preview": true,
+		}
+
+		// The bot token is part of the URI, it must not end up in the logs
+		err := postJSON(uri, nil, body)
+		if err != nil && s.BotToken != "" {
+			return fmt.Errorf("%s", strings.Replace(err.Error(), s.BotToken, "***", -1))
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// splitMessage cuts text into parts of at most limit bytes, between lines
+// unless a line alone is longer
+func splitMessage(text string, limit int) (parts []string) {
+	var part string
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if len(part)+len(line) > limit {
+			if strings.TrimSpace(part) != "" {
+				parts = append(parts, strings.TrimRight(part, "\n"))
+			}
+
+			part = ""
+		}
+
+		for len(line) > limit {
+			cut := limit
+			for !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+
+			parts = append(parts, line[:cut])
+			line = line[cut:]
+		}
+
+		part += line
+	}
+
+	if strings.TrimSpace(part) != "" {
+		parts = append(parts, strings.TrimRight(part, "\n"))
+	}
+
+	return parts
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitMessage(t *testing.T) {
+	line := strings.Repeat("a", 99) + "\n"
+
+	cases := []struct {
+		name	string
+		text	string
+		parts	int
+	}{
+		{"empty", "", 0},
+		{"short", "MTTR 1h\nMTBF 2h", 1},
+		{"at the limit", strings.Repeat("a", telegramMessageLimit), 1},
+		{"over the limit", strings.Repeat("a", telegramMessageLimit+1), 2},
+		{"many lines", strings.Repeat(line, 100), 3},
+	}
+
+	for _, c := range cases {
+		parts := splitMessage(c.text, telegramMessageLimit)
+
+		if len(parts) != c.parts {
+			t.Errorf("%s: got %d parts, want %d", c.name, len(parts), c.parts)
+		}
+
+		for _, part := range parts {
+			if len(part) > telegramMessageLimit {
+				t.Errorf("%s: part of %d bytes is over the limit", c.name, len(part))
+			}
+		}
+	}
+}
+
+func TestSplitMessageKeepsLines(t *testing.T) {
+	var lines []string
+	for i := 0; i < 500; i++ {
+		lines = append(lines, strings.Repeat("x", i%80))
+	}
+
+	text := strings.Join(lines, "\n")
+	parts := splitMessage(text, 1000)
+
+	if joined := strings.Join(parts, "\n"); joined != strings.TrimRight(text, "\n") {
+		t.Errorf("parts don't add up to the text, got %d bytes out of %d", len(joined), len(text))
+	}
+}
+
+func TestSplitMessageMultiByte(t *testing.T) {
+	// Two bytes each, a cut in the middle of one would be invalid UTF-8
+	text := "Projeto\n" + strings.Repeat("é", telegramMessageLimit) + "\n🚨 fim"
+	parts := splitMessage(text, telegramMessageLimit+1)
+
+	total := 0
+	for _, part := range parts {
+		if len(part) > telegramMessageLimit+1 {
+			t.Errorf("part of %d bytes is over the limit", len(part))
+		}
+
+		if !utf8.ValidString(part) {
+			t.Errorf("part isn't valid UTF-8: %q", part[:10])
+		}
+
+		total += utf8.RuneCountInString(part)
+	}
+
+	if want := utf8.RuneCountInString(strings.Replace(text, "\n", "", -1)); total != want {
+		t.Errorf("got %d runes, want %d", total, want)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// Phases measured by --timings, the crawling ones overlap since the pipeline
+// streams from one to the next
+const (
+	timingPreflight	= "preflight"
+	timingProjects	= "projects"
+	timingIssues	= "issues"
+	timingDetails	= "details"
+	timingEvents	= "events"
+	timingCompute	= "compute"
+	timingExport	= "export"
+)
+
+var timingPhases = []string{timingPreflight, timingProjects, timingIssues, timingDetails, timingEvents, timingCompute, timingExport}
+
+// Progress counts of the items each crawling phase fetches
+var timingItems = map[string]string{
+	timingProjects: "projects",
+	timingIssues: "issues",
+	timingDetails: "details",
+	timingEvents: "events",
+}
+
+// PhaseTiming is the wall time of a phase, from its first request starting to
+// its last one finishing, with its throughput. Retries are counted apart, as
+// the time they waited for a rate limit
+type PhaseTiming struct {
+	Phase			string	`json:"phase"`
+	Seconds			float64	`json:"seconds"`
+	Requests		int	`json:"requests"`
+	Retries			int	`json:"retries"`
+	WaitedSeconds		float64	`json:"waited_seconds"`
+	Items			int	`json:"items"`
+	RequestsPerSecond	float64	`json:"requests_per_second"`
+	ItemsPerSecond		float64	`json:"items_per_second"`
+}
+
+type phaseSpan struct {
+	start		time.Time
+	end		time.Time
+	requests	int
+	retries		int
+	waited		time.Duration
+}
+
+// addTiming extends the span of a phase with something that started at start
+// and just finished
+func (c *Calculator) addTiming(phase string, start time.Time, requests int) {
+	end := time.Now()
+
+	c.progress.mutex.Lock()
+	defer c.progress.mutex.Unlock()
+
+	if c.progress.spans == nil {
+		c.progress.spans = make(map[string]*phaseSpan)
+	}
+
+	span, ok := c.progress.spans[phase]
+	if !ok {
+		span = &phaseSpan{start: start, end: end}
+		c.progress.spans[phase] = span
+	}
+
+	if start.Before(span.start) {
+		span.start = start
+	}
+
+	if end.After(span.end) {
+		span.end = end
+	}
+
+	span.requests += requests
+}
+
+// addRetry counts a request of a phase sent again after waiting, its
+// attempts are already counted as requests
+func (c *Calculator) addRetry(phase string, waited time.Duration) {
+	c.progress.mutex.Lock()
+	defer c.progress.mutex.Unlock()
+
+	if span, ok := c.progress.spans[phase]; ok {
+		span.retries++
+		span.waited += waited
+	}
+}
+
+// Timings returns the timing of every phase that ran
+func (c *Calculator) Timings() (timings []PhaseTiming) {
+	c.progress.mutex.Lock()
+	defer c.progress.mutex.Unlock()
+
+	for _, phase := range timingPhases {
+		span, ok := c.progress.spans[phase]
+		if !ok {
+			continue
+		}
+
+		timing := PhaseTiming{
+			Phase: phase,
+			Seconds: span.end.Sub(span.start).Seconds(),
+			Requests: span.requests,
+			Retries: span.retries,
+			WaitedSeconds: span.waited.Seconds(),
+			Items: c.progress.counts[timingItems[phase]],
+		}
+
+		if timing.Seconds > 0 {
+			timing.RequestsPerSecond = float64(timing.Requests) / timing.Seconds
+			timing.ItemsPerSecond = float64(timing.Items) / timing.Seconds
+		}
+
+		timings = append(timings, timing)
+	}
+
+	return
+}
+
+func (c *Calculator) writeTimings(w io.Writer) {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+
+	fmt.Fprintln(table, "Phase\tWall time\tRequests\tRetries\tWaited\tItems\tRequests/s\tItems/s\t")
+
+	for _, timing := range c.Timings() {
+		wall := time.Duration(timing.Seconds * float64(time.Second)).Round(time.Millisecond)
+		waited := time.Duration(timing.WaitedSeconds * float64(time.Second)).Round(time.Millisecond)
+
+		fmt.Fprintf(table, "%s\t%v\t%d\t%d\t%v\t%d\t%.1f\t%.1f\t\n", timing.Phase, wall, timing.Requests, timing.Retries, waited, timing.Items, timing.RequestsPerSecond, timing.ItemsPerSecond)
+	}
+
+	table.Flush()
+}
@@ -0,0 +1,10 @@
+package main
+
+import (
+	"syscall"
+)
+
+const (
+	ioctlGetTermios	= syscall.TIOCGETA
+	ioctlSetTermios	= syscall.TIOCSETA
+)
@@ -0,0 +1,10 @@
+package main
+
+import (
+	"syscall"
+)
+
+const (
+	ioctlGetTermios	= syscall.TCGETS
+	ioctlSetTermios	= syscall.TCSETS
+)
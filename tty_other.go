@@ -0,0 +1,20 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package main
+
+import (
+	"fmt"
+)
+
+type terminal struct{}
+
+func openTerminal() (*terminal, error) {
+	return nil, fmt.Errorf("The terminal dashboard only runs on Linux and macOS.")
+}
+
+func (t *terminal) restore() {}
+
+func (t *terminal) size() (columns int, rows int) {
+	return 80, 24
+}
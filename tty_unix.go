@@ -0,0 +1,66 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// terminal puts stdin in raw mode for the dashboard and restores it after
+type terminal struct {
+	fd		uintptr
+	original	syscall.Termios
+}
+
+func openTerminal() (*terminal, error) {
+	t := &terminal{fd: os.Stdin.Fd()}
+
+	err := t.ioctl(ioctlGetTermios, unsafe.Pointer(&t.original))
+	if err != nil {
+		return nil, err
+	}
+
+	raw := t.original
+	raw.Iflag &^= syscall.ICRNL | syscall.IXON | syscall.BRKINT | syscall.INPCK | syscall.ISTRIP
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+
+	err = t.ioctl(ioctlSetTermios, unsafe.Pointer(&raw))
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (t *terminal) restore() {
+	t.ioctl(ioctlSetTermios, unsafe.Pointer(&t.original))
+}
+
+// size returns the columns and rows of the terminal, 80x24 when unknown
+func (t *terminal) size() (columns int, rows int) {
+	var size struct {
+		Rows, Columns, X, Y	uint16
+	}
+
+	err := t.ioctl(syscall.TIOCGWINSZ, unsafe.Pointer(&size))
+	if err != nil || size.Columns == 0 || size.Rows == 0 {
+		return 80, 24
+	}
+
+	return int(size.Columns), int(size.Rows)
+}
+
+func (t *terminal) ioctl(request uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, t.fd, request, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
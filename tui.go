@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	refreshInterval	= 100 * time.Millisecond
+	screenEnter	= "\x1b[?1049h\x1b[?25l"
+	screenLeave	= "\x1b[?25h\x1b[?1049l"
+	screenClear	= "\x1b[H\x1b[2J"
+	styleSelected	= "\x1b[7m"
+	styleBreached	= "\x1b[31m"
+	styleReset	= "\x1b[0m"
+)
+
+// Orders of the projects table, cycled with s
+var projectOrders = []string{"name", "mttr", "mtbf", "issues"}
+
+// dashboard is the state of the terminal dashboard, the project is nil on
+// the projects table and set when drilling into its issues
+type dashboard struct {
+	calculator	*Calculator
+	terminal	*terminal
+	started		time.Time
+	finished	bool
+	code		int
+	order		int
+	selected	int
+	project		*ProjectMetrics
+	issue		int
+}
+
+// Dashboard runs the calculation showing its progress in the terminal, then
+// lets the results be browsed, and returns the process exit code
+func (c *Calculator) Dashboard() int {
+	terminal, err := openTerminal()
+	if err != nil {
+		c.Log.Error(err.Error())
+		return exitConfig
+	}
+
+	defer terminal.restore()
+
+	os.Stdout.WriteString(screenEnter)
+	defer os.Stdout.WriteString(screenLeave)
+
+	// Logs would scroll the screen, failures are counted in the progress
+	c.Log.Out = ioutil.Discard
+
+	done := make(chan int, 1)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- exitFailure
+			}
+		}()
+
+		done <- c.Start()
+	}()
+
+	keys := make(chan string)
+	go readKeys(keys)
+
+	d := &dashboard{calculator: c, terminal: terminal, started: time.Now()}
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		d.render()
+
+		select {
+		case d.code = <-done:
+			d.finished = true
+		case key := <-keys:
+			if !d.handle(key) {
+				if !d.finished {
+					return exitFailure
+				}
+
+				return d.code
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+func readKeys(keys chan<- string) {
+	buffer := make([]byte, 16)
+
+	for {
+		n, err := os.Stdin.Read(buffer)
+		if err != nil {
+			close(keys)
+			return
+		}
+
+		switch key := string(buffer[:n]); key {
+		case "\x1b[A", "k":
+			keys <- "up"
+		case "\x1b[B", "j":
+			keys <- "down"
+		case "\r", "\n", "\x1b[C", "l":
+			keys <- "enter"
+		case "\x1b", "\x7f", "\x1b[D", "h":
+			keys <- "back"
+		case "\x03", "q":
+			keys <- "quit"
+		default:
+			keys <- key
+		}
+	}
+}
+
+// handle applies a key and tells whether the dashboard keeps running
+func (d *dashboard) handle(key string) bool {
+	if key == "quit" || key == "" {
+		return false
+	}
+
+	if !d.finished || d.calculator.report == nil {
+		return true
+	}
+
+	switch {
+	case key == "up" && d.project == nil && d.selected > 0:
+		d.selected--
+	case key == "down" && d.project == nil && d.selected < len(d.calculator.report.PerProject)-1:
+		d.selected++
+	case key == "up" && d.project != nil && d.issue > 0:
+		d.issue--
+	case key == "down" && d.project != nil && d.issue < len(d.issues())-1:
+		d.issue++
+	case key == "enter" && d.project == nil && len(d.calculator.report.PerProject) > 0:
+		project := d.projects()[d.selected]
+		d.project, d.issue = &project, 0
+	case key == "back":
+		d.project = nil
+	case key == "s" && d.project == nil:
+		d.order = (d.order + 1) % len(projectOrders)
+	}
+
+	return true
+}
+
+// projects returns the projects of the report in the current order, the
+// durations from the longest
+func (d *dashboard) projects() []ProjectMetrics {
+	projects := append([]ProjectMetrics(nil), d.calculator.report.PerProject...)
+	value := func(duration *float64) float64 {
+		if duration == nil {
+			return 0
+		}
+
+		return *duration
+	}
+
+	sort.SliceStable(projects, func(i, j int) bool {
+		switch projectOrders[d.order] {
+		case "mttr":
+			return value(projects[i].MTTR) > value(projects[j].MTTR)
+		case "mtbf":
+			return value(projects[i].MTBF) > value(projects[j].MTBF)
+		case "issues":
+			return projects[i].Issues > projects[j].Issues
+		}
+
+		return projects[i].Name < projects[j].Name
+	})
+
+	return projects
+}
+
+// issues returns the resolved issues of the project drilled into, the
+// slowest to resolve first
+func (d *dashboard) issues() (issues []ComputedActivity) {
+	for _, activity := range d.calculator.activities {
+		project := activity.Issue.Project
+		if project.Organization.Slug == d.project.Organization && project.Slug == d.project.Project {
+			issues = append(issues, activity)
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Duration > issues[j].Duration
+	})
+
+	return
+}
+
+func (d *dashboard) render() {
+	columns, rows := d.terminal.size()
+
+	var lines []string
+
+	switch {
+	case !d.finished:
+		lines = d.renderProgress()
+	case d.calculator.report == nil:
+		lines = append(d.renderProgress(), "", fmt.Sprintf("Run failed with exit code %d, press q to quit.", d.code))
+	case d.project == nil:
+		lines = d.renderProjects(rows)
+	default:
+		lines = d.renderIssues(rows)
+	}
+
+	var b bytes.Buffer
+	b.WriteString(screenClear)
+
+	for i, line := range lines {
+		if i >= rows {
+			break
+		}
+
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+
+		b.WriteString(truncate(line, columns))
+	}
+
+	os.Stdout.Write(b.Bytes())
+}
+
+func (d *dashboard) renderProgress() []string {
+	progress := d.calculator.Progress()
+	elapsed := time.Since(d.started).Truncate(time.Second)
+
+	lines := []string{
+		fmt.Sprintf("%s, %s for %v", binaryName, progress.Phase, elapsed),
+		"",
+	}
+
+	for _, kind := range []string{"organizations", "projects", "issues", "details", "events", "requests"} {
+		lines = append(lines, fmt.Sprintf("  %-14s %d", kind, progress.Counts[kind]))
+	}
+
+	lines = append(lines, fmt.Sprintf("  %-14s %d", "failures", progress.Failures), "", "q quits")
+
+	return lines
+}
+
+func (d *dashboard) renderProjects(rows int) []string {
+	report := d.calculator.report
+	lines := []string{
+		fmt.Sprintf("%d projects, %d issues, %d events, MTTR %s, MTBF %s", report.Projects, report.Issues, report.Events, formatOptional(report.MTTR), formatOptional(report.MTBF)),
+		"",
+		fmt.Sprintf("  %-32s %8s %8s %14s %14s", "Project", "Issues", "Events", "MTTR", "MTBF"),
+	}
+
+	projects := d.projects()
+	first := scrollOffset(d.selected, rows-len(lines)-2)
+
+	for i := first; i < len(projects) && len(lines) < rows-2; i++ {
+		project := projects[i]
+		line := fmt.Sprintf("  %-32s %8d %8d %14s %14s", project.Organization+"/"+project.Project, project.Issues, project.Events, formatOptional(project.MTTR), formatOptional(project.MTBF))
+
+		if project.Breaches > 0 {
+			line = styleBreached + line + styleReset
+		}
+
+		if i == d.selected {
+			line = styleSelected + line + styleReset
+		}
+
+		lines = append(lines, line)
+	}
+
+	return append(lines, "", fmt.Sprintf("up/down moves, enter opens, s sorts by %s, q quits", projectOrders[d.order]))
+}
+
+func (d *dashboard) renderIssues(rows int) []string {
+	lines := []string{
+		fmt.Sprintf("%s, MTTR %s", d.project.Name, formatOptional(d.project.MTTR)),
+		"",
+		fmt.Sprintf("  %-12s %-10s %-20s %-16s %14s", "Issue", "Status", "Owner", "Environment", "Resolved in"),
+	}
+
+	issues := d.issues()
+	first := scrollOffset(d.issue, rows-len(lines)-2)
+
+	for i := first; i < len(issues) && len(lines) < rows-2; i++ {
+		issue := issues[i]
+		line := fmt.Sprintf("  %-12s %-10s %-20.20s %-16.16s %14s", issue.Issue.Id, issue.Issue.Status, issue.Issue.Owner, issue.Issue.Environment, formatSeconds(issue.Duration))
+
+		if issue.Breached {
+			line = styleBreached + line + styleReset
+		}
+
+		if i == d.issue {
+			line = styleSelected + line + styleReset
+		}
+
+		lines = append(lines, line)
+	}
+
+	if len(issues) == 0 {
+		lines = append(lines, "  No resolved issues.")
+	}
+
+	return append(lines, "", "up/down moves, esc goes back, q quits")
+}
+
+// scrollOffset returns the first row to show so the selected one is visible
+func scrollOffset(selected int, visible int) int {
+	if visible < 1 || selected < visible {
+		return 0
+	}
+
+	return selected - visible + 1
+}
+
+func formatOptional(seconds *float64) string {
+	if seconds == nil {
+		return "-"
+	}
+
+	return formatSeconds(*seconds)
+}
+
+// truncate cuts a line to the width of the terminal, styles only wrap
+// whole lines so they are kept
+func truncate(line string, columns int) string {
+	prefix := ""
+
+	for _, style := range []string{styleSelected, styleBreached, styleSelected} {
+		if strings.HasPrefix(line, style) {
+			prefix += style
+			line = line[len(style):]
+		}
+	}
+
+	runes := []rune(strings.Replace(line, styleReset, "", -1))
+	if len(runes) > columns {
+		runes = runes[:columns]
+	}
+
+	if prefix == "" {
+		return string(runes)
+	}
+
+	return prefix + string(runes) + styleReset
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+const binaryName = "sentry-mttr-mtbf-calculator"
+
+// Build information, injected at build time through ldflags, see Makefile
+var (
+	version		= "dev"
+	commit		= "none"
+	buildDate	= "unknown"
+)
+
+func versionString() string {
+	return fmt.Sprintf("%s %s (commit %s, built %s, %s)", binaryName, version, commit, buildDate, runtime.Version())
+}